@@ -0,0 +1,193 @@
+// Package config persists Dialogo's account list and app-level settings.
+// Non-secret fields are stored via Fyne's App.Preferences() as a JSON blob;
+// passwords are kept out of that blob entirely and stored in the OS keyring
+// instead, so a dump of preferences.json never contains a credential.
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"fyne.io/fyne/v2"
+	"github.com/zalando/go-keyring"
+)
+
+const (
+	prefsKey       = "accounts"
+	lastUsedKey    = "lastUsedAccount"
+	autoConnectKey = "autoConnect"
+	roomsKey       = "rooms"
+	keyringService = "dialogo"
+)
+
+// Room is a joined MUC room remembered across restarts.
+type Room struct {
+	JID      string `json:"jid"`
+	Nickname string `json:"nickname"`
+}
+
+// TLSMode mirrors xmpp.TLSMode without importing the xmpp package, so
+// config stays independent of the connection subsystem.
+type TLSMode int
+
+const (
+	TLSDirect TLSMode = iota
+	STARTTLS
+	NoTLS
+)
+
+// Account is one saved XMPP login. Password is never serialized; it lives
+// only in the OS keyring, keyed by JID.
+type Account struct {
+	JID      string  `json:"jid"`
+	Server   string  `json:"server,omitempty"`
+	Port     int     `json:"port,omitempty"`
+	Resource string  `json:"resource,omitempty"`
+	TLS      TLSMode `json:"tls"`
+}
+
+// Store reads and writes Dialogo's persisted settings through a Fyne app's
+// Preferences and the OS keyring.
+type Store struct {
+	prefs fyne.Preferences
+}
+
+// NewStore creates a Store backed by the given Fyne app's preferences.
+func NewStore(app fyne.App) *Store {
+	return &Store{prefs: app.Preferences()}
+}
+
+// Accounts returns the saved account list, oldest-added first.
+func (s *Store) Accounts() []Account {
+	raw := s.prefs.String(prefsKey)
+	if raw == "" {
+		return nil
+	}
+	var accounts []Account
+	if err := json.Unmarshal([]byte(raw), &accounts); err != nil {
+		return nil
+	}
+	return accounts
+}
+
+// SaveAccount adds a new account or updates an existing one matched by JID,
+// and stores its password in the OS keyring.
+func (s *Store) SaveAccount(acc Account, password string) error {
+	accounts := s.Accounts()
+	found := false
+	for i, existing := range accounts {
+		if existing.JID == acc.JID {
+			accounts[i] = acc
+			found = true
+			break
+		}
+	}
+	if !found {
+		accounts = append(accounts, acc)
+	}
+
+	if err := s.writeAccounts(accounts); err != nil {
+		return err
+	}
+
+	if password != "" {
+		if err := keyring.Set(keyringService, acc.JID, password); err != nil {
+			return fmt.Errorf("config: saving credential for %s: %w", acc.JID, err)
+		}
+	}
+	return nil
+}
+
+// Account looks up a saved account by JID, so the caller can read back the
+// Server/Port/Resource/TLS it saved instead of just the password.
+func (s *Store) Account(jid string) (Account, bool) {
+	for _, acc := range s.Accounts() {
+		if acc.JID == jid {
+			return acc, true
+		}
+	}
+	return Account{}, false
+}
+
+// RemoveAccount deletes the account and its stored password.
+func (s *Store) RemoveAccount(jid string) error {
+	accounts := s.Accounts()
+	kept := accounts[:0]
+	for _, existing := range accounts {
+		if existing.JID != jid {
+			kept = append(kept, existing)
+		}
+	}
+	if err := s.writeAccounts(kept); err != nil {
+		return err
+	}
+
+	if err := keyring.Delete(keyringService, jid); err != nil && err != keyring.ErrNotFound {
+		return fmt.Errorf("config: removing credential for %s: %w", jid, err)
+	}
+	return nil
+}
+
+// Password looks up the keyring-stored credential for a JID.
+func (s *Store) Password(jid string) (string, error) {
+	password, err := keyring.Get(keyringService, jid)
+	if err != nil {
+		return "", fmt.Errorf("config: reading credential for %s: %w", jid, err)
+	}
+	return password, nil
+}
+
+// LastUsedJID returns the JID of the account that should be pre-populated
+// on startup, or "" if none has been used yet.
+func (s *Store) LastUsedJID() string {
+	return s.prefs.String(lastUsedKey)
+}
+
+// SetLastUsedJID records the active account so the next launch can restore it.
+func (s *Store) SetLastUsedJID(jid string) {
+	s.prefs.SetString(lastUsedKey, jid)
+}
+
+// AutoConnect reports whether Dialogo should connect the last-used account
+// automatically on launch, without waiting for the user to press Connect.
+func (s *Store) AutoConnect() bool {
+	return s.prefs.Bool(autoConnectKey)
+}
+
+// SetAutoConnect persists the auto-connect-on-launch flag.
+func (s *Store) SetAutoConnect(enabled bool) {
+	s.prefs.SetBool(autoConnectKey, enabled)
+}
+
+// Rooms returns the MUC rooms that should be rejoined on the next launch.
+func (s *Store) Rooms() []Room {
+	raw := s.prefs.String(roomsKey)
+	if raw == "" {
+		return nil
+	}
+	var rooms []Room
+	if err := json.Unmarshal([]byte(raw), &rooms); err != nil {
+		return nil
+	}
+	return rooms
+}
+
+// SetRooms persists the full set of currently joined rooms, so closing and
+// reopening a tab is reflected on the next launch.
+func (s *Store) SetRooms(rooms []Room) error {
+	raw, err := json.Marshal(rooms)
+	if err != nil {
+		return fmt.Errorf("config: marshaling rooms: %w", err)
+	}
+	s.prefs.SetString(roomsKey, string(raw))
+	return nil
+}
+
+func (s *Store) writeAccounts(accounts []Account) error {
+	raw, err := json.Marshal(accounts)
+	if err != nil {
+		return fmt.Errorf("config: marshaling accounts: %w", err)
+	}
+	s.prefs.SetString(prefsKey, string(raw))
+	return nil
+}
@@ -0,0 +1,245 @@
+// Package omemo implements the subset of XEP-0384 (OMEMO Encryption)
+// Dialogo needs: a per-account identity key pair, per-device trust
+// decisions, and message encryption/decryption for conversations the user
+// has opted into encrypting. Device-list publication and lookup are done by
+// the xmpp client. The identity and trust table are persisted in Dialogo's
+// SQLite history database, at rest behind a key derived from the account
+// password via Argon2id, never the password itself; peer sessions are held
+// in memory only and are not persisted.
+package omemo
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+
+	"golang.org/x/crypto/curve25519"
+	"golang.org/x/crypto/hkdf"
+)
+
+// DeviceID is the randomly generated per-install identifier XEP-0384
+// publishes in the device list (urn:xmpp:omemo:2:devicelist).
+type DeviceID uint32
+
+// Identity is the long-term X25519 key pair used to bootstrap sessions
+// with peer devices, plus the locally generated DeviceID.
+type Identity struct {
+	DeviceID   DeviceID
+	PrivateKey [32]byte
+	PublicKey  [32]byte
+}
+
+// TrustState records whether a peer device's key has been verified.
+type TrustState int
+
+const (
+	TrustUnverified TrustState = iota
+	TrustVerified
+	TrustRevoked
+)
+
+// PeerDevice is one of a contact's published OMEMO devices, discovered
+// over the network rather than assumed, plus the trust decision the user
+// has made about it.
+type PeerDevice struct {
+	JID         string
+	DeviceID    DeviceID
+	IdentityKey [32]byte
+	Trust       TrustState
+}
+
+// Fingerprint renders a public key as the grouped hex string OMEMO clients
+// conventionally display for manual verification.
+func Fingerprint(pub [32]byte) string {
+	sum := sha256.Sum256(pub[:])
+	hexStr := hex.EncodeToString(sum[:])
+	out := ""
+	for i := 0; i < len(hexStr); i += 8 {
+		if i > 0 {
+			out += " "
+		}
+		out += hexStr[i : i+8]
+	}
+	return out
+}
+
+// GenerateIdentity creates a new X25519 identity key pair and a random
+// device ID, as done once per account on first OMEMO use.
+func GenerateIdentity() (Identity, error) {
+	var id Identity
+	if _, err := rand.Read(id.PrivateKey[:]); err != nil {
+		return Identity{}, fmt.Errorf("omemo: generating identity: %w", err)
+	}
+	// Clamp per RFC 7748 so the scalar is a valid X25519 private key.
+	id.PrivateKey[0] &= 248
+	id.PrivateKey[31] &= 127
+	id.PrivateKey[31] |= 64
+
+	pub, err := curve25519.X25519(id.PrivateKey[:], curve25519.Basepoint)
+	if err != nil {
+		return Identity{}, fmt.Errorf("omemo: deriving public key: %w", err)
+	}
+	copy(id.PublicKey[:], pub)
+
+	var deviceIDBytes [4]byte
+	if _, err := rand.Read(deviceIDBytes[:]); err != nil {
+		return Identity{}, fmt.Errorf("omemo: generating device id: %w", err)
+	}
+	id.DeviceID = DeviceID(deviceIDBytes[0])<<24 | DeviceID(deviceIDBytes[1])<<16 |
+		DeviceID(deviceIDBytes[2])<<8 | DeviceID(deviceIDBytes[3])
+
+	return id, nil
+}
+
+// session is the symmetric key shared with one peer device after a
+// single X25519 Diffie-Hellman between each side's long-term identity
+// key, run through HKDF. This is a deliberately simplified stand-in for
+// XEP-0384's full X3DH (ephemeral and one-time prekeys) and Double
+// Ratchet (a fresh key per message): it gives two real devices a
+// matching key derived from identity keys each genuinely fetched over
+// the network, but it re-keys only when EstablishSession runs again and
+// provides no forward secrecy or deniability beyond that.
+type session struct {
+	sharedKey [32]byte
+}
+
+// Manager holds one account's OMEMO identity and active peer sessions, and
+// performs encryption/decryption for conversations the user has enabled it
+// for.
+type Manager struct {
+	identity Identity
+	sessions map[string]session // keyed by peer JID + device ID
+	trusted  map[string]TrustState
+}
+
+// NewManager wraps an already-generated (or loaded) identity.
+func NewManager(identity Identity) *Manager {
+	return &Manager{
+		identity: identity,
+		sessions: make(map[string]session),
+		trusted:  make(map[string]TrustState),
+	}
+}
+
+// Identity returns the account's long-term key pair.
+func (m *Manager) Identity() Identity {
+	return m.identity
+}
+
+// EstablishSession performs the ECDH agreement that seeds a session with a
+// peer device's published identity key, then runs the result through HKDF
+// instead of using the raw ECDH output as an AES key directly.
+func (m *Manager) EstablishSession(peer PeerDevice) error {
+	shared, err := curve25519.X25519(m.identity.PrivateKey[:], peer.IdentityKey[:])
+	if err != nil {
+		return fmt.Errorf("omemo: session with %s: %w", peer.JID, err)
+	}
+	key, err := deriveSessionKey(shared, m.identity.PublicKey, peer.IdentityKey)
+	if err != nil {
+		return fmt.Errorf("omemo: session with %s: %w", peer.JID, err)
+	}
+	m.sessions[sessionKey(peer.JID, peer.DeviceID)] = session{sharedKey: key}
+	return nil
+}
+
+// deriveSessionKey expands a raw ECDH output into an AES-256 key via
+// HKDF-SHA256, binding it to both parties' identity keys (sorted so the
+// info string matches regardless of which side derives it) so the same
+// ECDH output can't be reused verbatim as key material.
+func deriveSessionKey(shared, ours, theirs [32]byte) ([32]byte, error) {
+	var key [32]byte
+	info := make([]byte, 0, 64)
+	if bytes.Compare(ours[:], theirs[:]) <= 0 {
+		info = append(append(info, ours[:]...), theirs[:]...)
+	} else {
+		info = append(append(info, theirs[:]...), ours[:]...)
+	}
+	if _, err := io.ReadFull(hkdf.New(sha256.New, shared[:], nil, info), key[:]); err != nil {
+		return key, fmt.Errorf("deriving session key: %w", err)
+	}
+	return key, nil
+}
+
+// Encrypt seals plaintext for the given peer device using AES-256-GCM
+// under the session's shared key. The nonce is prepended to the returned
+// ciphertext. It refuses to encrypt to a device the user has revoked
+// trust for, so "Revoke trust" actually stops new messages going to that
+// device instead of only updating the fingerprint panel.
+func (m *Manager) Encrypt(peer PeerDevice, plaintext string) ([]byte, error) {
+	if m.trusted[Fingerprint(peer.IdentityKey)] == TrustRevoked {
+		return nil, fmt.Errorf("omemo: device %d for %s is revoked", peer.DeviceID, peer.JID)
+	}
+	sess, ok := m.sessions[sessionKey(peer.JID, peer.DeviceID)]
+	if !ok {
+		return nil, fmt.Errorf("omemo: no session with %s device %d", peer.JID, peer.DeviceID)
+	}
+
+	gcm, err := newGCM(sess.sharedKey)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, fmt.Errorf("omemo: nonce: %w", err)
+	}
+	return gcm.Seal(nonce, nonce, []byte(plaintext), nil), nil
+}
+
+// Decrypt opens a ciphertext previously produced by Encrypt for this
+// session. It refuses ciphertext from a device the user has revoked
+// trust for, matching Encrypt's refusal to seal to one.
+func (m *Manager) Decrypt(peer PeerDevice, ciphertext []byte) (string, error) {
+	if m.trusted[Fingerprint(peer.IdentityKey)] == TrustRevoked {
+		return "", fmt.Errorf("omemo: device %d for %s is revoked", peer.DeviceID, peer.JID)
+	}
+	sess, ok := m.sessions[sessionKey(peer.JID, peer.DeviceID)]
+	if !ok {
+		return "", fmt.Errorf("omemo: no session with %s device %d", peer.JID, peer.DeviceID)
+	}
+
+	gcm, err := newGCM(sess.sharedKey)
+	if err != nil {
+		return "", err
+	}
+	if len(ciphertext) < gcm.NonceSize() {
+		return "", fmt.Errorf("omemo: ciphertext too short")
+	}
+	nonce, sealed := ciphertext[:gcm.NonceSize()], ciphertext[gcm.NonceSize():]
+	plaintext, err := gcm.Open(nil, nonce, sealed, nil)
+	if err != nil {
+		return "", fmt.Errorf("omemo: decrypt: %w", err)
+	}
+	return string(plaintext), nil
+}
+
+// Trust returns the trust state recorded for a peer device's fingerprint.
+func (m *Manager) Trust(fingerprint string) TrustState {
+	return m.trusted[fingerprint]
+}
+
+// SetTrust manually trusts or revokes a peer device, as driven by the
+// fingerprint side panel's verify/revoke actions.
+func (m *Manager) SetTrust(fingerprint string, state TrustState) {
+	m.trusted[fingerprint] = state
+}
+
+func newGCM(key [32]byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key[:])
+	if err != nil {
+		return nil, fmt.Errorf("omemo: cipher: %w", err)
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("omemo: gcm: %w", err)
+	}
+	return gcm, nil
+}
+
+func sessionKey(jid string, deviceID DeviceID) string {
+	return fmt.Sprintf("%s#%d", jid, deviceID)
+}
@@ -0,0 +1,153 @@
+package omemo
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"golang.org/x/crypto/argon2"
+)
+
+// Store persists one account's OMEMO identity and trusted-device list in
+// the shared history SQLite database, encrypted at rest under a key
+// derived from the account password via Argon2id. The password itself is
+// never stored.
+type Store struct {
+	db *sql.DB
+}
+
+// NewStore wraps the same *sql.DB the history package uses, adding its own
+// table rather than a second database file.
+func NewStore(db *sql.DB) (*Store, error) {
+	const schema = `
+CREATE TABLE IF NOT EXISTS omemo_identity (
+	jid        TEXT PRIMARY KEY,
+	salt       BLOB NOT NULL,
+	nonce      BLOB NOT NULL,
+	ciphertext BLOB NOT NULL
+);
+`
+	if _, err := db.Exec(schema); err != nil {
+		return nil, fmt.Errorf("omemo: migrate: %w", err)
+	}
+	return &Store{db: db}, nil
+}
+
+// persisted is the JSON shape encrypted at rest: the identity key pair
+// plus every peer fingerprint the user has judged trusted or revoked.
+type persisted struct {
+	Identity Identity
+	Trust    map[string]TrustState
+}
+
+// Argon2id parameters tuned for an interactive unlock (~tens of ms),
+// consistent with the OWASP minimum recommendation for this primitive.
+const (
+	argonTime    = 1
+	argonMemory  = 64 * 1024 // KiB
+	argonThreads = 4
+	argonKeyLen  = 32
+)
+
+// Unlock loads the identity and trust table for jid, decrypting them with
+// a key derived from the account password. If nothing is stored yet, it
+// generates a fresh identity, encrypts it under the same derived key, and
+// persists it, so the first unlock after install creates the account's
+// OMEMO identity.
+func (s *Store) Unlock(jid, password string) (*Manager, error) {
+	var salt, nonce, ciphertext []byte
+	err := s.db.QueryRow(
+		`SELECT salt, nonce, ciphertext FROM omemo_identity WHERE jid = ?`, jid,
+	).Scan(&salt, &nonce, &ciphertext)
+
+	switch {
+	case err == sql.ErrNoRows:
+		return s.createIdentity(jid, password)
+	case err != nil:
+		return nil, fmt.Errorf("omemo: loading identity for %s: %w", jid, err)
+	}
+
+	key := deriveKey(password, salt)
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := gcm.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return nil, fmt.Errorf("omemo: wrong passphrase or corrupt identity for %s: %w", jid, err)
+	}
+
+	var p persisted
+	if err := json.Unmarshal(plaintext, &p); err != nil {
+		return nil, fmt.Errorf("omemo: decoding identity for %s: %w", jid, err)
+	}
+
+	mgr := NewManager(p.Identity)
+	mgr.trusted = p.Trust
+	return mgr, nil
+}
+
+// createIdentity generates and persists a brand-new identity the first
+// time an account unlocks OMEMO.
+func (s *Store) createIdentity(jid, password string) (*Manager, error) {
+	identity, err := GenerateIdentity()
+	if err != nil {
+		return nil, err
+	}
+	mgr := NewManager(identity)
+	if err := s.save(jid, password, mgr); err != nil {
+		return nil, err
+	}
+	return mgr, nil
+}
+
+// Save re-encrypts and writes back the manager's current identity and
+// trust table, e.g. after the user trusts or revokes a peer device.
+func (s *Store) Save(jid, password string, mgr *Manager) error {
+	return s.save(jid, password, mgr)
+}
+
+func (s *Store) save(jid, password string, mgr *Manager) error {
+	salt := make([]byte, 16)
+	if _, err := io.ReadFull(rand.Reader, salt); err != nil {
+		return fmt.Errorf("omemo: salt: %w", err)
+	}
+	key := deriveKey(password, salt)
+
+	plaintext, err := json.Marshal(persisted{Identity: mgr.identity, Trust: mgr.trusted})
+	if err != nil {
+		return fmt.Errorf("omemo: encoding identity: %w", err)
+	}
+
+	gcm, err := newGCM(key)
+	if err != nil {
+		return err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return fmt.Errorf("omemo: nonce: %w", err)
+	}
+	ciphertext := gcm.Seal(nil, nonce, plaintext, nil)
+
+	_, err = s.db.Exec(
+		`INSERT INTO omemo_identity (jid, salt, nonce, ciphertext) VALUES (?, ?, ?, ?)
+		 ON CONFLICT(jid) DO UPDATE SET salt = excluded.salt, nonce = excluded.nonce, ciphertext = excluded.ciphertext`,
+		jid, salt, nonce, ciphertext,
+	)
+	if err != nil {
+		return fmt.Errorf("omemo: saving identity for %s: %w", jid, err)
+	}
+	return nil
+}
+
+// deriveKey stretches the account password into an AES-256 key with
+// Argon2id, so a stolen history.sqlite3 file alone isn't enough to read
+// the identity key or trust table.
+func deriveKey(password string, salt []byte) [32]byte {
+	derived := argon2.IDKey([]byte(password), salt, argonTime, argonMemory, argonThreads, argonKeyLen)
+	var key [32]byte
+	copy(key[:], derived)
+	return key
+}
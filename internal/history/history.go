@@ -0,0 +1,242 @@
+// Package history stores every sent/received message in a local SQLite
+// database (modernc.org/sqlite, pure Go so Dialogo needs no cgo toolchain)
+// and provides the paging and search the chat UI lists from. It also backs
+// the client's XEP-0313 (Message Archive Management) backfill, which
+// writes server-side history into the same table when a conversation tab
+// is first opened; connecting alone does not trigger a backfill.
+package history
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/storage"
+	_ "modernc.org/sqlite"
+)
+
+// DefaultPath returns the on-disk location of Dialogo's message archive,
+// under the given Fyne app's document storage root.
+func DefaultPath(app fyne.App) string {
+	uri, err := storage.Child(app.Storage().RootURI(), "history.sqlite3")
+	if err != nil {
+		return "dialogo-history.sqlite3"
+	}
+	return uri.Path()
+}
+
+// Record is a single archived message.
+type Record struct {
+	ID        int64     `json:"id"`
+	JID       string    `json:"jid"` // the other party: contact bare JID or room JID
+	ThreadID  string    `json:"thread_id,omitempty"`
+	Direction string    `json:"direction"` // "in" or "out"
+	Body      string    `json:"body"`
+	Timestamp time.Time `json:"timestamp"`
+	// ArchiveID is the XEP-0313 MAM result id for a message backfilled
+	// from the server archive, empty for a live message. It's unique
+	// across the table so re-running a backfill upserts instead of
+	// duplicating rows.
+	ArchiveID string `json:"archive_id,omitempty"`
+}
+
+// Query narrows a history search by contact, substring, and date range.
+// Zero values mean "no filter" on that field.
+type Query struct {
+	JID       string
+	Substring string
+	From, To  time.Time
+	Limit     int
+	Offset    int
+}
+
+// Store is a SQLite-backed message archive.
+type Store struct {
+	db *sql.DB
+}
+
+// Open creates (if needed) and migrates the SQLite database at path.
+func Open(path string) (*Store, error) {
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, fmt.Errorf("history: open %s: %w", path, err)
+	}
+
+	const schema = `
+CREATE TABLE IF NOT EXISTS messages (
+	id         INTEGER PRIMARY KEY AUTOINCREMENT,
+	jid        TEXT NOT NULL,
+	thread_id  TEXT,
+	direction  TEXT NOT NULL,
+	body       TEXT NOT NULL,
+	timestamp  DATETIME NOT NULL,
+	archive_id TEXT
+);
+CREATE INDEX IF NOT EXISTS idx_messages_jid_ts ON messages(jid, timestamp);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_messages_archive_id ON messages(archive_id) WHERE archive_id IS NOT NULL;
+`
+	if _, err := db.Exec(schema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("history: migrate: %w", err)
+	}
+	if err := addArchiveIDColumn(db); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return &Store{db: db}, nil
+}
+
+// addArchiveIDColumn adds the archive_id column and its partial unique
+// index to a database created before MAM dedup existed; CREATE TABLE IF
+// NOT EXISTS above leaves an already-existing messages table untouched.
+func addArchiveIDColumn(db *sql.DB) error {
+	rows, err := db.Query(`PRAGMA table_info(messages)`)
+	if err != nil {
+		return fmt.Errorf("history: inspect schema: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			cid, notNull, pk int
+			name, ctype      string
+			dflt             sql.NullString
+		)
+		if err := rows.Scan(&cid, &name, &ctype, &notNull, &dflt, &pk); err != nil {
+			return fmt.Errorf("history: inspect schema: %w", err)
+		}
+		if name == "archive_id" {
+			return rows.Err()
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("history: inspect schema: %w", err)
+	}
+
+	if _, err := db.Exec(`ALTER TABLE messages ADD COLUMN archive_id TEXT`); err != nil {
+		return fmt.Errorf("history: add archive_id column: %w", err)
+	}
+	if _, err := db.Exec(`CREATE UNIQUE INDEX IF NOT EXISTS idx_messages_archive_id ON messages(archive_id) WHERE archive_id IS NOT NULL`); err != nil {
+		return fmt.Errorf("history: index archive_id: %w", err)
+	}
+	return nil
+}
+
+// Append persists a sent or received message, or, for one carrying an
+// ArchiveID, upserts it: re-running a MAM backfill resolves to the same
+// archive_id and is silently skipped instead of duplicating the row.
+// inserted reports whether a new row was actually written, so a caller
+// that also mirrors history into an in-memory list can skip a message a
+// repeated backfill already delivered.
+func (s *Store) Append(r Record) (inserted bool, err error) {
+	var archiveID sql.NullString
+	if r.ArchiveID != "" {
+		archiveID = sql.NullString{String: r.ArchiveID, Valid: true}
+	}
+	result, err := s.db.Exec(
+		`INSERT INTO messages (jid, thread_id, direction, body, timestamp, archive_id)
+		 VALUES (?, ?, ?, ?, ?, ?)
+		 ON CONFLICT(archive_id) WHERE archive_id IS NOT NULL DO NOTHING`,
+		r.JID, r.ThreadID, r.Direction, r.Body, r.Timestamp, archiveID,
+	)
+	if err != nil {
+		return false, fmt.Errorf("history: append: %w", err)
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("history: append: %w", err)
+	}
+	return n > 0, nil
+}
+
+// Page returns one page of a conversation's history, most recent first,
+// for the chat UI's virtualized list to lazily fetch as the user scrolls.
+func (s *Store) Page(jid string, limit, offset int) ([]Record, error) {
+	return s.query(
+		`SELECT id, jid, thread_id, direction, body, timestamp, archive_id FROM messages
+		 WHERE jid = ? ORDER BY timestamp DESC LIMIT ? OFFSET ?`,
+		jid, limit, offset,
+	)
+}
+
+// Search filters messages by substring, contact, and/or date range.
+func (s *Store) Search(q Query) ([]Record, error) {
+	sqlText := `SELECT id, jid, thread_id, direction, body, timestamp, archive_id FROM messages WHERE 1=1`
+	var args []interface{}
+
+	if q.JID != "" {
+		sqlText += ` AND jid = ?`
+		args = append(args, q.JID)
+	}
+	if q.Substring != "" {
+		sqlText += ` AND body LIKE ?`
+		args = append(args, "%"+q.Substring+"%")
+	}
+	if !q.From.IsZero() {
+		sqlText += ` AND timestamp >= ?`
+		args = append(args, q.From)
+	}
+	if !q.To.IsZero() {
+		sqlText += ` AND timestamp <= ?`
+		args = append(args, q.To)
+	}
+
+	sqlText += ` ORDER BY timestamp DESC`
+	limit := q.Limit
+	if limit <= 0 {
+		limit = 200
+	}
+	sqlText += ` LIMIT ? OFFSET ?`
+	args = append(args, limit, q.Offset)
+
+	return s.query(sqlText, args...)
+}
+
+// ExportJSON marshals a conversation's full history as JSON, for the chat
+// UI's per-conversation export action.
+func (s *Store) ExportJSON(jid string) ([]byte, error) {
+	records, err := s.query(
+		`SELECT id, jid, thread_id, direction, body, timestamp, archive_id FROM messages WHERE jid = ? ORDER BY timestamp ASC`,
+		jid,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return json.MarshalIndent(records, "", "  ")
+}
+
+func (s *Store) query(sqlText string, args ...interface{}) ([]Record, error) {
+	rows, err := s.db.Query(sqlText, args...)
+	if err != nil {
+		return nil, fmt.Errorf("history: query: %w", err)
+	}
+	defer rows.Close()
+
+	var records []Record
+	for rows.Next() {
+		var r Record
+		var threadID, archiveID sql.NullString
+		if err := rows.Scan(&r.ID, &r.JID, &threadID, &r.Direction, &r.Body, &r.Timestamp, &archiveID); err != nil {
+			return nil, fmt.Errorf("history: scan: %w", err)
+		}
+		r.ThreadID = threadID.String
+		r.ArchiveID = archiveID.String
+		records = append(records, r)
+	}
+	return records, rows.Err()
+}
+
+// Close closes the underlying database handle.
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+// DB exposes the underlying connection so related subsystems (e.g.
+// internal/crypto/omemo) can add their own tables to the same SQLite file
+// instead of opening a second database.
+func (s *Store) DB() *sql.DB {
+	return s.db
+}
@@ -0,0 +1,195 @@
+// Package theme provides Dialogo's Dark/Light/System theme variants plus a
+// user-selectable accent color, replacing the single hard-coded dark
+// palette main.go used to define inline. The chosen mode and accent are
+// persisted through a Fyne app's Preferences.
+package theme
+
+import (
+	"image/color"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/theme"
+)
+
+// Mode selects which of Dialogo's theme variants is active.
+type Mode int
+
+const (
+	// ModeDark always renders the dark palette, regardless of OS setting.
+	ModeDark Mode = iota
+	// ModeLight always renders the light palette.
+	ModeLight
+	// ModeSystem follows the OS light/dark preference, the same way Fyne's
+	// own default theme does.
+	ModeSystem
+)
+
+const (
+	modeKey   = "theme.mode"
+	accentKey = "theme.accent"
+)
+
+// DefaultAccent is used when no accent has been saved yet.
+var DefaultAccent = color.NRGBA{R: 79, G: 70, B: 229, A: 255}
+
+// dialogoTheme implements fyne.Theme for all three modes. mode pins the
+// variant Color() reports for ColorNameBackground/Foreground/etc; for
+// ModeSystem it instead honors the variant Fyne passes in, which already
+// reflects the OS appearance.
+type dialogoTheme struct {
+	mode   Mode
+	accent color.Color
+}
+
+// DarkTheme returns a theme that always renders Dialogo's dark palette.
+func DarkTheme(accent color.Color) fyne.Theme {
+	return &dialogoTheme{mode: ModeDark, accent: accent}
+}
+
+// LightTheme returns a theme that always renders Fyne's light palette with
+// Dialogo's accent applied.
+func LightTheme(accent color.Color) fyne.Theme {
+	return &dialogoTheme{mode: ModeLight, accent: accent}
+}
+
+// SystemTheme returns a theme that follows the OS appearance setting,
+// switching between Dialogo's dark and light palettes as it changes.
+func SystemTheme(accent color.Color) fyne.Theme {
+	return &dialogoTheme{mode: ModeSystem, accent: accent}
+}
+
+func (t *dialogoTheme) variant(v fyne.ThemeVariant) fyne.ThemeVariant {
+	switch t.mode {
+	case ModeDark:
+		return theme.VariantDark
+	case ModeLight:
+		return theme.VariantLight
+	default:
+		return v
+	}
+}
+
+func (t *dialogoTheme) Color(name fyne.ThemeColorName, variant fyne.ThemeVariant) color.Color {
+	v := t.variant(variant)
+
+	if name == theme.ColorNamePrimary {
+		return t.accent
+	}
+
+	if v == theme.VariantLight {
+		return theme.DefaultTheme().Color(name, v)
+	}
+
+	switch name {
+	case theme.ColorNameBackground:
+		return color.NRGBA{R: 30, G: 30, B: 30, A: 255}
+	case theme.ColorNameForeground:
+		return color.NRGBA{R: 220, G: 220, B: 220, A: 255}
+	case theme.ColorNameButton:
+		return color.NRGBA{R: 70, G: 70, B: 70, A: 255}
+	case theme.ColorNameInputBackground:
+		return color.NRGBA{R: 45, G: 45, B: 45, A: 255}
+	case theme.ColorNameDisabledButton:
+		return color.NRGBA{R: 60, G: 60, B: 60, A: 255}
+	case theme.ColorNameDisabled:
+		return color.NRGBA{R: 80, G: 80, B: 80, A: 255}
+	default:
+		return theme.DefaultTheme().Color(name, v)
+	}
+}
+
+func (t *dialogoTheme) Font(style fyne.TextStyle) fyne.Resource {
+	return theme.DefaultTheme().Font(style)
+}
+
+func (t *dialogoTheme) Icon(name fyne.ThemeIconName) fyne.Resource {
+	return theme.DefaultTheme().Icon(name)
+}
+
+func (t *dialogoTheme) Size(name fyne.ThemeSizeName) float32 {
+	return theme.DefaultTheme().Size(name)
+}
+
+// ForMode builds the theme for a given Mode and accent in one call, so
+// callers don't need a switch of their own.
+func ForMode(mode Mode, accent color.Color) fyne.Theme {
+	switch mode {
+	case ModeDark:
+		return DarkTheme(accent)
+	case ModeLight:
+		return LightTheme(accent)
+	default:
+		return SystemTheme(accent)
+	}
+}
+
+// LoadMode reads the last-saved theme mode from the app's preferences,
+// defaulting to ModeDark (Dialogo's original look) if none was saved.
+func LoadMode(app fyne.App) Mode {
+	return Mode(app.Preferences().IntWithFallback(modeKey, int(ModeDark)))
+}
+
+// SaveMode persists the chosen theme mode.
+func SaveMode(app fyne.App, mode Mode) {
+	app.Preferences().SetInt(modeKey, int(mode))
+}
+
+// LoadAccent reads the last-saved accent color, defaulting to DefaultAccent.
+func LoadAccent(app fyne.App) color.Color {
+	hex := app.Preferences().String(accentKey)
+	if hex == "" {
+		return DefaultAccent
+	}
+	return hexToColor(hex, DefaultAccent)
+}
+
+// SaveAccent persists the chosen accent color as a hex string.
+func SaveAccent(app fyne.App, accent color.Color) {
+	app.Preferences().SetString(accentKey, colorToHex(accent))
+}
+
+func colorToHex(c color.Color) string {
+	r, g, b, _ := c.RGBA()
+	return string([]byte{
+		hexDigit(byte(r >> 12)), hexDigit(byte(r >> 8 & 0xf)),
+		hexDigit(byte(g >> 12)), hexDigit(byte(g >> 8 & 0xf)),
+		hexDigit(byte(b >> 12)), hexDigit(byte(b >> 8 & 0xf)),
+	})
+}
+
+func hexToColor(hex string, fallback color.Color) color.Color {
+	if len(hex) != 6 {
+		return fallback
+	}
+	var r, g, b byte
+	for i, pair := range [][2]byte{{hex[0], hex[1]}, {hex[2], hex[3]}, {hex[4], hex[5]}} {
+		v := digitValue(pair[0])<<4 | digitValue(pair[1])
+		switch i {
+		case 0:
+			r = v
+		case 1:
+			g = v
+		case 2:
+			b = v
+		}
+	}
+	return color.NRGBA{R: r, G: g, B: b, A: 255}
+}
+
+func hexDigit(v byte) byte {
+	const digits = "0123456789abcdef"
+	return digits[v&0xf]
+}
+
+func digitValue(c byte) byte {
+	switch {
+	case c >= '0' && c <= '9':
+		return c - '0'
+	case c >= 'a' && c <= 'f':
+		return c - 'a' + 10
+	case c >= 'A' && c <= 'F':
+		return c - 'A' + 10
+	default:
+		return 0
+	}
+}
@@ -0,0 +1,97 @@
+package ui
+
+import (
+	"fmt"
+	"runtime"
+	"runtime/debug"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/canvas"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+)
+
+// releaseNotes is shown in the About dialog until release notes are pulled
+// from a changelog file or remote feed.
+const releaseNotes = `### What's new
+
+- Real XMPP login, roster, and presence
+- Multi-account switching with keyring-backed credentials
+- Multi-user chat rooms as closable tabs`
+
+// ShowAbout opens the Help -> About dialog: app identity from
+// FyneApp.toml's metadata, release notes, reference links, and a button
+// that copies a diagnostics bundle for bug reports.
+func (m *MainUI) ShowAbout() {
+	meta := m.app.Metadata()
+
+	icon := canvas.NewImageFromResource(m.app.Icon())
+	icon.FillMode = canvas.ImageFillContain
+	icon.SetMinSize(fyne.NewSize(64, 64))
+
+	title := widget.NewLabel(fmt.Sprintf("%s %s (build %d)", meta.Name, meta.Version, meta.Build))
+	title.TextStyle = fyne.TextStyle{Bold: true}
+
+	notes := widget.NewRichTextFromMarkdown(releaseNotes)
+	notes.Wrapping = fyne.TextWrapWord
+
+	links := container.NewHBox(
+		widget.NewHyperlink("Project homepage", parseURL("https://github.com/Adhvay0505/Dialogo")),
+		widget.NewHyperlink("XMPP Core (RFC 6120)", parseURL("https://www.rfc-editor.org/rfc/rfc6120")),
+		widget.NewHyperlink("Issue tracker", parseURL("https://github.com/Adhvay0505/Dialogo/issues")),
+	)
+
+	copyBtn := widget.NewButton("Copy diagnostics", func() {
+		m.window.Clipboard().SetContent(m.diagnostics())
+	})
+
+	content := container.NewVBox(
+		container.NewHBox(icon, title),
+		notes,
+		links,
+		copyBtn,
+	)
+
+	dialog.ShowCustom("About Dialogo", "Close", content, *m.window)
+}
+
+// diagnostics dumps the information a bug report needs: Fyne/Go version,
+// OS, and what the currently connected server has advertised.
+func (m *MainUI) diagnostics() string {
+	fyneVersion := "unknown"
+	if info, ok := debug.ReadBuildInfo(); ok {
+		for _, dep := range info.Deps {
+			if dep.Path == "fyne.io/fyne/v2" {
+				fyneVersion = dep.Version
+			}
+		}
+	}
+	return fmt.Sprintf(
+		"Dialogo %s (build %d)\nFyne: %s\nGo: %s\nOS/Arch: %s/%s\nServer capabilities: %s\n",
+		m.app.Metadata().Version,
+		m.app.Metadata().Build,
+		fyneVersion,
+		runtime.Version(),
+		runtime.GOOS, runtime.GOARCH,
+		m.serverCapabilities(),
+	)
+}
+
+// serverCapabilities returns a placeholder: no disco#info query is made,
+// so it never lists the server's advertised features. It reports
+// "(not connected)" when no chat tab is active and "unknown" otherwise.
+func (m *MainUI) serverCapabilities() string {
+	if m.activeJID == "" {
+		return "(not connected)"
+	}
+	return "unknown (disco#info not yet queried)"
+}
+
+func parseURL(raw string) *fyne.URL {
+	u, err := fyne.ParseURL(raw)
+	if err != nil {
+		return nil
+	}
+	return u
+}
@@ -0,0 +1,317 @@
+package ui
+
+import (
+	"fmt"
+	"time"
+
+	"dialogo/internal/crypto/omemo"
+	"dialogo/internal/history"
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+)
+
+// omemoPrefix marks a message body as OMEMO ciphertext so the receiving
+// side knows to run it through Manager.Decrypt before displaying it,
+// instead of showing the base64 payload as plain text.
+const omemoPrefix = "omemo:"
+
+const pageSize = 50
+
+// conversationView is the chat history + message entry shared by 1:1 tabs
+// and the chat pane of a MUC room tab. History is paged lazily out of the
+// history.Store rather than held entirely in memory, so a long-lived
+// conversation doesn't grow the in-memory message list without bound.
+type conversationView struct {
+	jid    string
+	store  *history.Store
+	window *fyne.Window
+
+	list       *widget.List
+	records    []history.Record // ascending chronological order, oldest first
+	nextOffset int
+
+	searchEntry  *widget.Entry
+	messageEntry *widget.Entry
+	inputRow     fyne.CanvasObject
+
+	manager   *omemo.Manager
+	fetchPeer func() (omemo.PeerDevice, error)
+	saveTrust func()
+	peer      omemo.PeerDevice
+	encrypted bool
+	lockBtn   *widget.Button
+}
+
+// newConversationView builds an empty conversation; onSend is called with
+// the entry text whenever the user presses Send or Enter. store may be nil
+// (e.g. in contexts without a history database), in which case messages
+// are kept in memory only for the life of the tab. mgr may be nil, which
+// disables the OMEMO lock toggle and fingerprint panel entirely (used for
+// MUC rooms, which this package doesn't offer OMEMO for). fetchPeer looks
+// up the peer's published device over the network and is only called
+// once, the first time the user enables encryption. saveTrust persists the
+// manager's trust table after a verify/revoke decision; it may be nil
+// alongside mgr.
+func newConversationView(jid string, store *history.Store, window *fyne.Window, mgr *omemo.Manager, fetchPeer func() (omemo.PeerDevice, error), saveTrust func(), onSend func(body string)) *conversationView {
+	v := &conversationView{jid: jid, store: store, window: window, manager: mgr, fetchPeer: fetchPeer, saveTrust: saveTrust}
+
+	v.list = widget.NewList(
+		func() int { return len(v.records) },
+		func() fyne.CanvasObject { return widget.NewLabel("message") },
+		func(id widget.ListItemID, o fyne.CanvasObject) {
+			r := v.records[id]
+			who := "Them"
+			if r.Direction == "out" {
+				who = "Me"
+			}
+			o.(*widget.Label).SetText(who + ": " + r.Body + "  [" + r.Timestamp.Format("15:04") + "]")
+		},
+	)
+
+	v.searchEntry = widget.NewEntry()
+	v.searchEntry.SetPlaceHolder("Search this conversation...")
+	v.searchEntry.OnChanged = func(text string) { v.runSearch(text) }
+
+	v.messageEntry = widget.NewEntry()
+	v.messageEntry.SetPlaceHolder("Type your message here...")
+	v.messageEntry.OnSubmitted = func(text string) {
+		if text == "" {
+			return
+		}
+		onSend(text)
+		v.messageEntry.SetText("")
+	}
+
+	sendBtn := widget.NewButton("Send", func() {
+		v.messageEntry.OnSubmitted(v.messageEntry.Text)
+	})
+	sendBtn.Importance = widget.HighImportance
+
+	trailing := fyne.CanvasObject(sendBtn)
+	if v.manager != nil {
+		v.lockBtn = widget.NewButton("🔓", func() { v.toggleEncryption() })
+		trailing = container.NewHBox(v.lockBtn, sendBtn)
+	}
+	v.inputRow = container.NewBorder(nil, nil, nil, trailing, v.messageEntry)
+
+	v.loadInitial()
+	return v
+}
+
+// Content lays out the search bar, the paged message list, a "load older"
+// control, the export action, and the input row.
+func (v *conversationView) Content() fyne.CanvasObject {
+	loadOlderBtn := widget.NewButton("Load older messages", func() { v.loadOlder() })
+	exportBtn := widget.NewButton("Export to JSON", func() { v.export() })
+
+	actions := container.NewHBox(loadOlderBtn, exportBtn)
+	if v.manager != nil {
+		actions.Add(widget.NewButton("Fingerprints", func() { v.showFingerprints() }))
+	}
+
+	top := container.NewVBox(v.searchEntry, actions)
+	return container.NewBorder(top, v.inputRow, nil, nil, v.list)
+}
+
+// toggleEncryption flips whether outgoing messages in this conversation are
+// sealed with OMEMO. On first enable it fetches the peer's published
+// device over the network and establishes the session with it.
+func (v *conversationView) toggleEncryption() {
+	if v.manager == nil {
+		return
+	}
+	if !v.encrypted && v.peer.JID == "" {
+		peer, err := v.fetchPeer()
+		if err != nil {
+			dialog.ShowError(fmt.Errorf("omemo: %s hasn't published an OMEMO device: %w", v.jid, err), *v.window)
+			return
+		}
+		if err := v.manager.EstablishSession(peer); err != nil {
+			dialog.ShowError(err, *v.window)
+			return
+		}
+		v.peer = peer
+	}
+	v.encrypted = !v.encrypted
+	if v.encrypted {
+		v.lockBtn.SetText("🔒")
+	} else {
+		v.lockBtn.SetText("🔓")
+	}
+}
+
+// Encrypted reports whether the user has enabled OMEMO for this conversation.
+func (v *conversationView) Encrypted() bool {
+	return v.encrypted
+}
+
+// Manager returns the OMEMO manager wired up for this conversation, or nil
+// if OMEMO isn't available here (e.g. a MUC room).
+func (v *conversationView) Manager() *omemo.Manager {
+	return v.manager
+}
+
+// Peer returns the peer device this conversation's OMEMO session targets.
+func (v *conversationView) Peer() omemo.PeerDevice {
+	return v.peer
+}
+
+// showFingerprints opens a dialog with this account's and the peer's
+// fingerprints and lets the user manually verify or revoke the peer device.
+func (v *conversationView) showFingerprints() {
+	if v.manager == nil {
+		return
+	}
+	if !v.encrypted {
+		dialog.ShowInformation("OMEMO", "Enable encryption for this conversation first to establish a session with the peer's device.", *v.window)
+		return
+	}
+	ours := omemo.Fingerprint(v.manager.Identity().PublicKey)
+	theirs := omemo.Fingerprint(v.peer.IdentityKey)
+
+	ownLabel := widget.NewLabel("Your fingerprint:\n" + ours)
+	peerLabel := widget.NewLabel("Their fingerprint (" + trustLabel(v.manager.Trust(theirs)) + "):\n" + theirs)
+
+	verifyBtn := widget.NewButton("Mark verified", func() {
+		v.manager.SetTrust(theirs, omemo.TrustVerified)
+		v.persistTrust()
+		dialog.ShowInformation("OMEMO", "Device marked verified.", *v.window)
+	})
+	revokeBtn := widget.NewButton("Revoke trust", func() {
+		v.manager.SetTrust(theirs, omemo.TrustRevoked)
+		v.persistTrust()
+		dialog.ShowInformation("OMEMO", "Device trust revoked.", *v.window)
+	})
+
+	content := container.NewVBox(ownLabel, widget.NewSeparator(), peerLabel, container.NewHBox(verifyBtn, revokeBtn))
+	dialog.ShowCustom("Device fingerprints", "Close", content, *v.window)
+}
+
+// persistTrust writes the manager's trust table back to disk, if this
+// conversation was wired up with a way to do so.
+func (v *conversationView) persistTrust() {
+	if v.saveTrust != nil {
+		v.saveTrust()
+	}
+}
+
+func trustLabel(state omemo.TrustState) string {
+	switch state {
+	case omemo.TrustVerified:
+		return "verified"
+	case omemo.TrustRevoked:
+		return "revoked"
+	default:
+		return "unverified"
+	}
+}
+
+// loadInitial pages in the most recent messages for this conversation.
+func (v *conversationView) loadInitial() {
+	if v.store == nil {
+		return
+	}
+	page, err := v.store.Page(v.jid, pageSize, 0)
+	if err != nil {
+		return
+	}
+	v.records = reverseRecords(page)
+	v.nextOffset = len(page)
+	v.list.Refresh()
+}
+
+// loadOlder pages in the next-oldest batch and prepends it to the history
+// already loaded, which is how a virtualized list backfills on demand
+// instead of holding the full archive in memory.
+func (v *conversationView) loadOlder() {
+	if v.store == nil {
+		return
+	}
+	page, err := v.store.Page(v.jid, pageSize, v.nextOffset)
+	if err != nil || len(page) == 0 {
+		return
+	}
+	v.records = append(reverseRecords(page), v.records...)
+	v.nextOffset += len(page)
+	v.list.Refresh()
+}
+
+// runSearch filters the displayed history by substring; clearing the
+// search box restores the normal paged view.
+func (v *conversationView) runSearch(substring string) {
+	if v.store == nil {
+		return
+	}
+	if substring == "" {
+		v.loadInitial()
+		return
+	}
+	results, err := v.store.Search(history.Query{JID: v.jid, Substring: substring})
+	if err != nil {
+		return
+	}
+	v.records = reverseRecords(results)
+	v.list.Refresh()
+}
+
+// export writes this conversation's full history to a JSON file the user
+// picks via a save dialog.
+func (v *conversationView) export() {
+	if v.store == nil {
+		return
+	}
+	data, err := v.store.ExportJSON(v.jid)
+	if err != nil {
+		dialog.ShowError(err, *v.window)
+		return
+	}
+
+	dialog.ShowFileSave(func(writer fyne.URIWriteCloser, err error) {
+		if err != nil || writer == nil {
+			return
+		}
+		defer writer.Close()
+		_, _ = writer.Write(data)
+	}, *v.window)
+}
+
+// Append records a message (persisting it if a history store is wired up)
+// and appends it to the currently displayed page. sent is the time the
+// message was actually sent (e.g. a MAM <delay> stamp); a zero value
+// falls back to now. archiveID is the MAM result id for a backfilled
+// message, used to dedupe a re-run archive fetch, or "" for a live one; a
+// backfill the store has already recorded is dropped instead of showing a
+// duplicate row.
+func (v *conversationView) Append(who, body string, sent time.Time, archiveID string) {
+	if sent.IsZero() {
+		sent = time.Now()
+	}
+	direction := "in"
+	if who == "Me" {
+		direction = "out"
+	}
+	record := history.Record{JID: v.jid, Direction: direction, Body: body, Timestamp: sent, ArchiveID: archiveID}
+
+	if v.store != nil {
+		inserted, err := v.store.Append(record)
+		if err != nil {
+			return
+		}
+		if !inserted {
+			return
+		}
+	}
+
+	v.records = append(v.records, record)
+	v.list.Refresh()
+}
+
+func reverseRecords(records []history.Record) []history.Record {
+	reversed := make([]history.Record, len(records))
+	for i, r := range records {
+		reversed[len(records)-1-i] = r
+	}
+	return reversed
+}
@@ -0,0 +1,106 @@
+package ui
+
+import (
+	"fmt"
+
+	"dialogo/internal/history"
+	"dialogo/internal/xmpp"
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+)
+
+// roomView is the tab content for a joined MUC room (XEP-0045): the shared
+// chat history plus a participant list, topic display, and admin actions
+// that a 1:1 conversation tab doesn't need.
+type roomView struct {
+	client   xmpp.Client
+	window   *fyne.Window
+	jid      string
+	nickname string
+
+	chat        *conversationView
+	topicLabel  *widget.Label
+	occupants   []xmpp.Occupant
+	participants *widget.List
+}
+
+// newRoomView builds the tab content for a just-joined room.
+func newRoomView(client xmpp.Client, hist *history.Store, window *fyne.Window, roomJID, nickname string, onSend func(body string)) *roomView {
+	r := &roomView{
+		client:   client,
+		window:   window,
+		jid:      roomJID,
+		nickname: nickname,
+		chat:     newConversationView(roomJID, hist, window, nil, nil, nil, onSend),
+	}
+
+	r.topicLabel = widget.NewLabel("Topic: (none)")
+	r.topicLabel.Wrapping = fyne.TextWrapWord
+
+	r.participants = widget.NewList(
+		func() int { return len(r.occupants) },
+		func() fyne.CanvasObject {
+			return container.NewBorder(nil, nil, nil, widget.NewButtonWithIcon("", nil, nil), widget.NewLabel("nick"))
+		},
+		func(id widget.ListItemID, o fyne.CanvasObject) {
+			occ := r.occupants[id]
+			row := o.(*fyne.Container)
+			row.Objects[0].(*widget.Label).SetText(fmt.Sprintf("%s (%s)", occ.Nick, occ.Role))
+		},
+	)
+	r.participants.OnSelected = func(id widget.ListItemID) {
+		r.showAdminActions(r.occupants[id])
+	}
+
+	return r
+}
+
+// Content lays out the room tab: chat in the center, topic above it, and
+// the participant list docked to the right.
+func (r *roomView) Content() fyne.CanvasObject {
+	left := container.NewBorder(r.topicLabel, nil, nil, nil, r.chat.Content())
+	split := container.NewHSplit(left, r.participants)
+	split.SetOffset(0.75)
+	return split
+}
+
+// HandleRoomUpdate applies a RoomUpdate event to this room's occupant list
+// and topic display.
+func (r *roomView) HandleRoomUpdate(update xmpp.RoomUpdate) {
+	r.occupants = update.Occupants
+	r.participants.Refresh()
+	if update.Topic != "" {
+		r.topicLabel.SetText("Topic: " + update.Topic)
+	}
+}
+
+// showAdminActions offers kick/ban for a selected occupant. XEP-0045
+// reserves these to room moderators; the server rejects the IQ for
+// anyone else, so no local permission check is done here. Ban is disabled
+// when the room hasn't disclosed the occupant's real JID (a semi/fully
+// anonymous room), since an affiliation change can't be addressed by nick.
+func (r *roomView) showAdminActions(occ xmpp.Occupant) {
+	kick := widget.NewButton("Kick", func() {
+		if err := r.client.Kick(r.jid, occ.Nick, "kicked by moderator"); err != nil {
+			dialog.ShowError(err, *r.window)
+		}
+	})
+	ban := widget.NewButton("Ban", func() {
+		if err := r.client.Ban(r.jid, occ.JID, "banned by moderator"); err != nil {
+			dialog.ShowError(err, *r.window)
+		}
+	})
+	ban.Disable()
+	if occ.JID != "" {
+		ban.Enable()
+	}
+	content := container.NewVBox(widget.NewLabel(occ.Nick), container.NewHBox(kick, ban))
+	dialog.ShowCustom(occ.Nick, "Close", content, *r.window)
+}
+
+// Leave sends unavailable presence so the server drops this occupant.
+func (r *roomView) Leave() error {
+	return r.client.LeaveRoom(r.jid, r.nickname)
+}
@@ -1,30 +1,76 @@
 package ui
 
 import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"image/color"
+	"log"
+	"strings"
+	"time"
+
+	"dialogo/internal/config"
+	"dialogo/internal/crypto/omemo"
+	"dialogo/internal/history"
+	dialogotheme "dialogo/internal/theme"
+	"dialogo/internal/xmpp"
 	"fyne.io/fyne/v2"
 	"fyne.io/fyne/v2/canvas"
 	"fyne.io/fyne/v2/container"
 	"fyne.io/fyne/v2/dialog"
+	fynetheme "fyne.io/fyne/v2/theme"
 	"fyne.io/fyne/v2/widget"
-	"image/color"
 )
 
 // MainUI holds the main application UI state
 type MainUI struct {
+	app         fyne.App
 	window      *fyne.Window
-	toggleTheme func()
-	isDarkMode  bool
+	setTheme    func(dialogotheme.Mode)
+	themeMode   dialogotheme.Mode
 	font        fyne.Resource
-	toggle      *toggleSwitch
+	toggle      *themeSelector
+	store       *config.Store
+	history     *history.Store
+	omemoStore  *omemo.Store
+	omemoMgr    *omemo.Manager
+	omemoJID    string
+	omemoPassword string
+
+	client      xmpp.Client
+	roster      []xmpp.Contact
+	contactList *widget.List
+	activeJID   string
+
+	tabs          *container.DocTabs
+	conversations map[string]*conversationView
+	rooms         map[string]*roomView
+	tabItems      map[string]*container.TabItem
+	unread        map[string]int
+
+	jidEntry      *widget.Entry
+	passwordEntry *widget.Entry
+	accountSelect *widget.Select
 }
 
-// NewMainUI creates a new MainUI instance
-func NewMainUI(window *fyne.Window, toggleTheme func(), isDark bool, font fyne.Resource) *MainUI {
+// NewMainUI creates a new MainUI instance. omemoStore may be nil (e.g. if
+// the history database failed to open), in which case OMEMO is disabled
+// for the session.
+func NewMainUI(app fyne.App, window *fyne.Window, setTheme func(dialogotheme.Mode), mode dialogotheme.Mode, font fyne.Resource, store *config.Store, hist *history.Store, omemoStore *omemo.Store) *MainUI {
 	return &MainUI{
-		window:     window, 
-		toggleTheme: toggleTheme,
-		isDarkMode:  isDark,
-		font:        font,
+		app:           app,
+		window:        window,
+		setTheme:      setTheme,
+		themeMode:     mode,
+		font:          font,
+		store:         store,
+		history:       hist,
+		omemoStore:    omemoStore,
+		client:        xmpp.New(),
+		conversations: make(map[string]*conversationView),
+		rooms:         make(map[string]*roomView),
+		tabItems:      make(map[string]*container.TabItem),
+		unread:        make(map[string]int),
 	}
 }
 
@@ -36,7 +82,7 @@ func (m *MainUI) BuildUI() fyne.CanvasObject {
 	titleLabel.Alignment = fyne.TextAlignLeading
 	
 	// FIXED toggle switch
-	m.toggle = newToggleSwitch(m.isDarkMode, m.toggleTheme)
+	m.toggle = newThemeSelector(m.themeMode, m.setTheme)
 	
 	// TRUE TOP-RIGHT corner
 	header := container.NewBorder(
@@ -46,124 +92,637 @@ func (m *MainUI) BuildUI() fyne.CanvasObject {
 	// Login form
 	loginLabel := widget.NewLabel("XMPP Login")
 	loginLabel.TextStyle = fyne.TextStyle{Bold: true}
-	
-	jidEntry := widget.NewEntry()
-	jidEntry.SetPlaceHolder("user@jabber.example.com")
-	
-	passwordEntry := widget.NewPasswordEntry()
-	passwordEntry.SetPlaceHolder("Password")
-	
+
+	m.jidEntry = widget.NewEntry()
+	m.jidEntry.SetPlaceHolder("user@jabber.example.com")
+
+	m.passwordEntry = widget.NewPasswordEntry()
+	m.passwordEntry.SetPlaceHolder("Password")
+
+	m.accountSelect = widget.NewSelect(m.accountJIDs(), func(jid string) {
+		m.selectAccount(jid)
+	})
+	m.accountSelect.PlaceHolder = "Saved accounts"
+
+	accountsBtn := widget.NewButton("Accounts", func() {
+		m.showAccountsDialog()
+	})
+
 	connectBtn := widget.NewButton("Connect", func() {
-		m.handleConnect(jidEntry.Text, passwordEntry.Text)
+		m.handleConnect(m.jidEntry.Text, m.passwordEntry.Text)
 	})
 	connectBtn.Importance = widget.HighImportance
-	
+
 	form := container.NewVBox(
 		loginLabel,
+		m.accountSelect,
 		widget.NewForm(
-			widget.NewFormItem("JID", jidEntry),
-			widget.NewFormItem("Password", passwordEntry),
+			widget.NewFormItem("JID", m.jidEntry),
+			widget.NewFormItem("Password", m.passwordEntry),
 		),
-		connectBtn,
+		container.NewHBox(connectBtn, accountsBtn),
 		container.NewPadded(widget.NewLabel("")),
 	)
 
+	m.prefillLastAccount()
+
 	// Status + Chat
 	statusLabel := widget.NewLabel("Status: Disconnected")
-	
-	chatArea := widget.NewRichTextFromMarkdown("**Chat messages will appear here...**")
-	chatArea.Wrapping = fyne.TextWrapWord
-	
-	messageEntry := widget.NewEntry()
-	messageEntry.SetPlaceHolder("Type your message here...")
-	
-	sendBtn := widget.NewButton("Send", func() {
-		m.handleSend(statusLabel, messageEntry.Text)
-		messageEntry.SetText("")
+
+	m.contactList = widget.NewList(
+		func() int { return len(m.roster) },
+		func() fyne.CanvasObject { return widget.NewLabel("contact") },
+		func(id widget.ListItemID, o fyne.CanvasObject) {
+			c := m.roster[id]
+			label := c.JID
+			if c.Presence.Online {
+				label = "● " + label
+			} else {
+				label = "○ " + label
+			}
+			o.(*widget.Label).SetText(label)
+		},
+	)
+	m.contactList.OnSelected = func(id widget.ListItemID) {
+		m.openConversation(m.roster[id].JID, false)
+	}
+
+	joinRoomBtn := widget.NewButton("Join Room", func() {
+		m.showJoinRoomDialog(statusLabel)
 	})
-	sendBtn.Importance = widget.HighImportance
-	
-	messageContainer := container.NewMax(messageEntry)
-	messageContainer.Resize(fyne.NewSize(900, 60))
-	sendContainer := container.NewMax(sendBtn)
-	sendContainer.Resize(fyne.NewSize(120, 60))
-	
-	inputRow := container.NewHBox(messageContainer, sendContainer)
 
-	chatSection := container.NewBorder(nil, inputRow, nil, nil, container.NewScroll(chatArea))
+	contactsPane := container.NewBorder(nil, joinRoomBtn, nil, nil, m.contactList)
 
-	vsplit := container.NewHSplit(form, chatSection)
-	vsplit.SetOffset(0.3)
+	m.tabs = container.NewDocTabs()
+	m.tabs.OnSelected = func(item *container.TabItem) {
+		m.activeJID = m.tabJID(item)
+		m.unread[m.activeJID] = 0
+		m.refreshTabLabel(m.activeJID)
+	}
+	m.tabs.OnClosed = func(item *container.TabItem) {
+		m.closeConversation(m.tabJID(item))
+	}
+
+	contactsAndChat := container.NewHSplit(contactsPane, m.tabs)
+	contactsAndChat.SetOffset(0.25)
+
+	vsplit := container.NewHSplit(form, contactsAndChat)
+	vsplit.SetOffset(0.25)
 
 	content := container.NewBorder(header, statusLabel, nil, nil, vsplit)
 
+	m.restoreRooms()
+
 	accent := canvas.NewRectangle(color.NRGBA{R: 79, G: 70, B: 229, A: 30})
 
+	go m.consumeEvents(statusLabel)
+
+	if m.store != nil && m.store.AutoConnect() && m.jidEntry.Text != "" {
+		m.handleConnect(m.jidEntry.Text, m.passwordEntry.Text)
+	}
+
 	return container.NewStack(content, accent)
 }
 
+// consumeEvents drains client events for the lifetime of the UI, updating
+// the roster, chat history, and status label as the connection changes.
+// It runs on its own goroutine, so every branch that touches a widget or
+// tab is wrapped in fyne.Do to hand the mutation back to the UI thread
+// instead of violating Fyne's single-goroutine drawing contract.
+func (m *MainUI) consumeEvents(status *widget.Label) {
+	for ev := range m.client.Events() {
+		ev := ev
+		switch {
+		case ev.Err != nil:
+			fyne.Do(func() { status.SetText("Status: " + ev.Err.Error()) })
+		case ev.Message != nil:
+			jid := ev.Message.From
+			if jid == "" {
+				jid = ev.Message.To
+			}
+			fyne.Do(func() { m.appendMessage(bareJID(jid), *ev.Message) })
+		case ev.Roster != nil:
+			fyne.Do(func() {
+				m.roster = ev.Roster
+				m.contactList.Refresh()
+			})
+		case ev.Presence != nil:
+			fyne.Do(func() { m.contactList.Refresh() })
+		case ev.Room != nil:
+			fyne.Do(func() {
+				if r, ok := m.rooms[ev.Room.Room]; ok {
+					r.HandleRoomUpdate(*ev.Room)
+				}
+			})
+		default:
+			fyne.Do(func() { m.setConnState(status, ev.State) })
+		}
+	}
+}
+
+// appendMessage routes an incoming message into its conversation or room
+// tab, opening a 1:1 tab on first contact and bumping the unread badge for
+// any tab that isn't currently selected.
+func (m *MainUI) appendMessage(jid string, msg xmpp.Message) {
+	if r, ok := m.rooms[jid]; ok {
+		r.chat.Append(msg.From, msg.Body, msg.Sent, msg.ArchiveID)
+	} else {
+		m.openConversation(jid, false)
+		view := m.conversations[jid]
+		view.Append(msg.From, m.decryptIfNeeded(view, msg.Body), msg.Sent, msg.ArchiveID)
+	}
+
+	if jid != m.activeJID {
+		m.unread[jid]++
+		m.refreshTabLabel(jid)
+	}
+}
+
+func (m *MainUI) setConnState(status *widget.Label, state xmpp.ConnState) {
+	switch state {
+	case xmpp.StateConnecting:
+		status.SetText("Status: Connecting...")
+	case xmpp.StateConnected:
+		status.SetText("Status: Connected")
+	case xmpp.StateReconnecting:
+		status.SetText("Status: Reconnecting...")
+	case xmpp.StateDisconnected:
+		status.SetText("Status: Disconnected")
+	}
+}
+
+// openConversation switches to the tab for jid, creating a closable 1:1
+// conversation tab the first time it's selected.
+func (m *MainUI) openConversation(jid string, isRoom bool) {
+	if item, ok := m.tabItems[jid]; ok {
+		m.tabs.Select(item)
+		return
+	}
+	if isRoom {
+		return // rooms are opened via joinRoom, which creates their tab directly
+	}
+
+	view := newConversationView(jid, m.history, m.window, m.omemoMgr, func() (omemo.PeerDevice, error) {
+		return m.fetchPeerDevice(jid)
+	}, m.persistOmemoTrust, func(body string) {
+		m.sendTo(jid, body)
+	})
+	m.conversations[jid] = view
+	m.addTab(jid, jid, view.Content())
+	m.backfillArchive(jid)
+}
+
+// backfillArchive asks the server for MAM history on first opening a
+// conversation; received stanzas arrive as ordinary Message events and are
+// persisted through the normal appendMessage path.
+func (m *MainUI) backfillArchive(jid string) {
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		_, _ = m.client.FetchArchive(ctx, jid, time.Now())
+	}()
+}
+
+// addTab wires a closable tab with an unread badge into m.tabs and the
+// lookup tables consumeEvents/openConversation rely on.
+func (m *MainUI) addTab(jid, label string, content fyne.CanvasObject) {
+	item := container.NewTabItem(label, content)
+	m.tabItems[jid] = item
+	m.tabs.Append(item)
+	m.tabs.Select(item)
+	m.activeJID = jid
+}
+
+// tabJID reverse-looks-up the jid backing a tab item.
+func (m *MainUI) tabJID(item *container.TabItem) string {
+	for jid, candidate := range m.tabItems {
+		if candidate == item {
+			return jid
+		}
+	}
+	return ""
+}
+
+// refreshTabLabel shows an unread-count badge on a tab that isn't selected.
+func (m *MainUI) refreshTabLabel(jid string) {
+	item, ok := m.tabItems[jid]
+	if !ok {
+		return
+	}
+	if count := m.unread[jid]; count > 0 {
+		item.Text = fmt.Sprintf("%s (%d)", jid, count)
+	} else {
+		item.Text = jid
+	}
+	m.tabs.Refresh()
+}
+
+// closeConversation cleans up after the tab for jid has already been
+// removed from m.tabs (by the user clicking its close button), sending
+// unavailable presence for rooms so the server drops the occupant.
+func (m *MainUI) closeConversation(jid string) {
+	if r, ok := m.rooms[jid]; ok {
+		_ = r.Leave()
+		delete(m.rooms, jid)
+		m.persistRooms()
+	}
+	delete(m.conversations, jid)
+	delete(m.tabItems, jid)
+	delete(m.unread, jid)
+}
+
+// showJoinRoomDialog prompts for "room@conference.server/nickname" and
+// joins the room on confirm.
+func (m *MainUI) showJoinRoomDialog(status *widget.Label) {
+	entry := widget.NewEntry()
+	entry.SetPlaceHolder("room@conference.example.com/nickname")
+
+	dialog.ShowForm("Join Room", "Join", "Cancel",
+		[]*widget.FormItem{widget.NewFormItem("Room", entry)},
+		func(confirmed bool) {
+			if !confirmed || entry.Text == "" {
+				return
+			}
+			m.joinRoom(entry.Text, status)
+		}, *m.window)
+}
+
+// joinRoom splits "room@host/nick", joins via the client, opens its tab,
+// and persists it so it's rejoined on the next launch.
+func (m *MainUI) joinRoom(roomWithNick string, status *widget.Label) {
+	room, nick := splitRoomJID(roomWithNick)
+	if nick == "" {
+		nick = "dialogo"
+	}
+
+	if err := m.client.JoinRoom(room, nick); err != nil {
+		status.SetText("Status: " + err.Error())
+		return
+	}
+
+	view := newRoomView(m.client, m.history, m.window, room, nick, func(body string) {
+		m.sendTo(room, body)
+	})
+	m.rooms[room] = view
+	m.addTab(room, room, view.Content())
+	m.persistRooms()
+}
+
+// bareJID strips a JID's resource (everything from the first "/"), so a
+// contact's full JID (user@host/resource) and a MUC occupant's JID
+// (room@host/nick) both key into the same tab as the bare JID the
+// contact list and room map use.
+func bareJID(full string) string {
+	if i := strings.IndexByte(full, '/'); i >= 0 {
+		return full[:i]
+	}
+	return full
+}
+
+func splitRoomJID(roomWithNick string) (room, nick string) {
+	for i := len(roomWithNick) - 1; i >= 0; i-- {
+		if roomWithNick[i] == '/' {
+			return roomWithNick[:i], roomWithNick[i+1:]
+		}
+	}
+	return roomWithNick, ""
+}
+
+// restoreRooms rejoins every room the config store remembers from the last
+// session, once a connection exists.
+func (m *MainUI) restoreRooms() {
+	if m.store == nil {
+		return
+	}
+	for _, r := range m.store.Rooms() {
+		view := newRoomView(m.client, m.history, m.window, r.JID, r.Nickname, func(body string) {
+			m.sendTo(r.JID, body)
+		})
+		m.rooms[r.JID] = view
+		m.addTab(r.JID, r.JID, view.Content())
+	}
+}
+
+// persistRooms writes the currently joined rooms back to the config store.
+func (m *MainUI) persistRooms() {
+	if m.store == nil {
+		return
+	}
+	var rooms []config.Room
+	for jid, r := range m.rooms {
+		rooms = append(rooms, config.Room{JID: jid, Nickname: r.nickname})
+	}
+	_ = m.store.SetRooms(rooms)
+}
+
 func (m *MainUI) handleConnect(jid, password string) {
-	dialog.ShowInformation("Connect", "Connecting to "+jid+"...", *m.window)
+	cfg := xmpp.Config{JID: jid, Password: password, TLS: xmpp.STARTTLS}
+	if m.store != nil {
+		if acc, ok := m.store.Account(jid); ok {
+			cfg.Server = acc.Server
+			cfg.Port = acc.Port
+			cfg.Resource = acc.Resource
+			cfg.TLS = xmpp.TLSMode(acc.TLS)
+		}
+		m.store.SetLastUsedJID(jid)
+	}
+	if m.omemoStore != nil {
+		if mgr, err := m.omemoStore.Unlock(jid, password); err == nil {
+			m.omemoMgr = mgr
+			m.omemoJID = jid
+			m.omemoPassword = password
+		} else {
+			log.Println("dialogo: omemo disabled:", err)
+		}
+	}
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		if err := m.client.Connect(ctx, cfg); err != nil {
+			dialog.ShowError(err, *m.window)
+			return
+		}
+		for jid, r := range m.rooms {
+			_ = m.client.JoinRoom(jid, r.nickname)
+		}
+		if m.omemoMgr != nil {
+			identity := m.omemoMgr.Identity()
+			_ = m.client.PublishDeviceList(uint32(identity.DeviceID), identity.PublicKey)
+		}
+	}()
 }
 
-func (m *MainUI) handleSend(status *widget.Label, message string) {
-	if message != "" {
-		status.SetText("Status: Message sent!")
+// persistOmemoTrust re-encrypts and writes back the OMEMO identity and
+// trust table, so a verify/revoke decision survives a restart instead of
+// living only in the in-memory Manager.
+func (m *MainUI) persistOmemoTrust() {
+	if m.omemoStore == nil || m.omemoMgr == nil {
+		return
+	}
+	if err := m.omemoStore.Save(m.omemoJID, m.omemoPassword, m.omemoMgr); err != nil {
+		log.Println("dialogo: saving omemo trust:", err)
 	}
 }
 
-// FIXED: Proper canvas toggle widget
-type toggleSwitch struct {
-	widget.BaseWidget
-	isDark   bool
-	toggleFn func()
-	trackBG  *canvas.Circle
-	knob     *canvas.Circle
+// fetchPeerDevice looks up jid's published OMEMO device over the network,
+// the real key material EstablishSession needs to seed a session.
+func (m *MainUI) fetchPeerDevice(jid string) (omemo.PeerDevice, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	key, err := m.client.FetchDeviceKey(ctx, jid)
+	if err != nil {
+		return omemo.PeerDevice{}, err
+	}
+	return omemo.PeerDevice{JID: jid, DeviceID: omemo.DeviceID(key.DeviceID), IdentityKey: key.IdentityKey}, nil
+}
+
+// decryptIfNeeded opens an OMEMO-sealed incoming body for display, falling
+// back to the raw body if the conversation has no OMEMO session (or
+// decryption fails, e.g. the message predates EstablishSession).
+func (m *MainUI) decryptIfNeeded(view *conversationView, body string) string {
+	if view.Manager() == nil || !strings.HasPrefix(body, omemoPrefix) {
+		return body
+	}
+	ciphertext, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(body, omemoPrefix))
+	if err != nil {
+		return body
+	}
+	plaintext, err := view.Manager().Decrypt(view.Peer(), ciphertext)
+	if err != nil {
+		return body
+	}
+	return plaintext
+}
+
+// accountJIDs returns the saved account JIDs for the dropdown, or nil if
+// no config store is wired up (e.g. in tests).
+func (m *MainUI) accountJIDs() []string {
+	if m.store == nil {
+		return nil
+	}
+	var jids []string
+	for _, acc := range m.store.Accounts() {
+		jids = append(jids, acc.JID)
+	}
+	return jids
+}
+
+// selectAccount fills the login form from a saved account's JID and its
+// keyring-stored password.
+func (m *MainUI) selectAccount(jid string) {
+	m.jidEntry.SetText(jid)
+	if m.store == nil {
+		return
+	}
+	if password, err := m.store.Password(jid); err == nil {
+		m.passwordEntry.SetText(password)
+	}
+}
+
+// prefillLastAccount populates the login form from the last-used account
+// on startup, so returning users skip manual entry.
+func (m *MainUI) prefillLastAccount() {
+	if m.store == nil {
+		return
+	}
+	jid := m.store.LastUsedJID()
+	if jid == "" {
+		return
+	}
+	m.accountSelect.SetSelected(jid)
+}
+
+// showAccountsDialog opens the Accounts panel to add, edit, or remove
+// saved accounts and toggle auto-connect-on-launch.
+func (m *MainUI) showAccountsDialog() {
+	if m.store == nil {
+		return
+	}
+
+	jidEntry := widget.NewEntry()
+	jidEntry.SetPlaceHolder("user@jabber.example.com")
+	passwordEntry := widget.NewPasswordEntry()
+	serverEntry := widget.NewEntry()
+	serverEntry.SetPlaceHolder("Server override (optional)")
+
+	autoConnect := widget.NewCheck("Auto-connect on launch", func(checked bool) {
+		m.store.SetAutoConnect(checked)
+	})
+	autoConnect.SetChecked(m.store.AutoConnect())
+
+	selected := -1
+	accountsList := widget.NewList(
+		func() int { return len(m.store.Accounts()) },
+		func() fyne.CanvasObject { return widget.NewLabel("account") },
+		func(id widget.ListItemID, o fyne.CanvasObject) {
+			o.(*widget.Label).SetText(m.store.Accounts()[id].JID)
+		},
+	)
+	accountsList.OnSelected = func(id widget.ListItemID) { selected = id }
+
+	saveBtn := widget.NewButton("Save account", func() {
+		if jidEntry.Text == "" {
+			return
+		}
+		acc := config.Account{JID: jidEntry.Text, Server: serverEntry.Text, TLS: config.STARTTLS}
+		if err := m.store.SaveAccount(acc, passwordEntry.Text); err != nil {
+			dialog.ShowError(err, *m.window)
+			return
+		}
+		m.accountSelect.Options = m.accountJIDs()
+		m.accountSelect.Refresh()
+		accountsList.Refresh()
+	})
+
+	removeBtn := widget.NewButton("Remove selected", func() {
+		if selected < 0 || selected >= len(m.store.Accounts()) {
+			return
+		}
+		if err := m.store.RemoveAccount(m.store.Accounts()[selected].JID); err != nil {
+			dialog.ShowError(err, *m.window)
+			return
+		}
+		m.accountSelect.Options = m.accountJIDs()
+		m.accountSelect.Refresh()
+		accountsList.Refresh()
+	})
+
+	content := container.NewVBox(
+		accountsList,
+		widget.NewForm(
+			widget.NewFormItem("JID", jidEntry),
+			widget.NewFormItem("Password", passwordEntry),
+			widget.NewFormItem("Server", serverEntry),
+		),
+		container.NewHBox(saveBtn, removeBtn),
+		autoConnect,
+	)
+
+	dialog.ShowCustom("Accounts", "Close", content, *m.window)
 }
 
-func newToggleSwitch(isDark bool, toggleFn func()) *toggleSwitch {
-	t := &toggleSwitch{
-		isDark:   isDark,
-		toggleFn: toggleFn,
+// sendTo delivers a message typed into a specific tab, whether that tab is
+// a 1:1 conversation or a joined room.
+func (m *MainUI) sendTo(jid, message string) {
+	if message == "" {
+		return
+	}
+
+	wire := message
+	if view, ok := m.conversations[jid]; ok && view.Encrypted() {
+		ciphertext, err := view.Manager().Encrypt(view.Peer(), message)
+		if err != nil {
+			dialog.ShowError(err, *m.window)
+			return
+		}
+		wire = omemoPrefix + base64.StdEncoding.EncodeToString(ciphertext)
+	}
+
+	if err := m.client.Send(jid, wire); err != nil {
+		return
 	}
+	if r, ok := m.rooms[jid]; ok {
+		r.chat.Append("Me", message, time.Now(), "")
+		return
+	}
+	if view, ok := m.conversations[jid]; ok {
+		view.Append("Me", message, time.Now(), "")
+	}
+}
+
+// themeSelector is a three-position Dark/Light/System control: tapping it
+// cycles to the next mode. The knob's inset comes from the active theme's
+// padding size; the minimum size (90x32) and the label text size are fixed.
+type themeSelector struct {
+	widget.BaseWidget
+	mode   dialogotheme.Mode
+	onPick func(dialogotheme.Mode)
+	track  *canvas.Rectangle
+	knob   *canvas.Circle
+	labels [3]*canvas.Text
+}
+
+func newThemeSelector(mode dialogotheme.Mode, onPick func(dialogotheme.Mode)) *themeSelector {
+	t := &themeSelector{mode: mode, onPick: onPick}
 	t.ExtendBaseWidget(t)
-	
-	t.trackBG = canvas.NewCircle(color.NRGBA{R: 60, G: 60, B: 60, A: 255})
-	t.trackBG.Resize(fyne.NewSize(66, 36))
-	
+
+	t.track = canvas.NewRectangle(color.NRGBA{R: 60, G: 60, B: 60, A: 255})
 	t.knob = canvas.NewCircle(color.NRGBA{R: 255, G: 255, B: 255, A: 255})
-	t.knob.Resize(fyne.NewSize(28, 28))
-	
-	t.updateVisuals()
+
+	names := [3]string{"D", "L", "S"}
+	for i, name := range names {
+		t.labels[i] = canvas.NewText(name, color.NRGBA{R: 200, G: 200, B: 200, A: 255})
+		t.labels[i].TextSize = 11
+		t.labels[i].Alignment = fyne.TextAlignCenter
+	}
+
 	return t
 }
 
-func (t *toggleSwitch) CreateRenderer() fyne.WidgetRenderer {
-	container := container.NewWithoutLayout(t.trackBG, t.knob)
-	return widget.NewSimpleRenderer(container)
+func (t *themeSelector) CreateRenderer() fyne.WidgetRenderer {
+	objects := []fyne.CanvasObject{t.track, t.labels[0], t.labels[1], t.labels[2], t.knob}
+	c := container.NewWithoutLayout(objects...)
+	return &themeSelectorRenderer{selector: t, container: c}
 }
 
-func (t *toggleSwitch) MinSize() fyne.Size {
-	return fyne.NewSize(75, 45)
+func (t *themeSelector) MinSize() fyne.Size {
+	return fyne.NewSize(90, 32)
 }
 
-func (t *toggleSwitch) updateVisuals() {
-	if t.isDark {
-		t.trackBG.FillColor = color.NRGBA{R: 45, G: 45, B: 45, A: 255}
-		t.knob.FillColor = color.NRGBA{R: 255, G: 255, B: 255, A: 255}
-		t.knob.Move(fyne.NewPos(34, 4))
-	} else {
-		t.trackBG.FillColor = color.NRGBA{R: 220, G: 220, B: 220, A: 255}
-		t.knob.FillColor = color.NRGBA{R: 30, G: 30, B: 30, A: 255}
-		t.knob.Move(fyne.NewPos(4, 4))
+func (t *themeSelector) Tapped(_ *fyne.PointEvent) {
+	t.mode = (t.mode + 1) % 3
+	t.Refresh()
+	if t.onPick != nil {
+		t.onPick(t.mode)
 	}
 }
 
-func (t *toggleSwitch) Tapped(_ *fyne.PointEvent) {
-	t.isDark = !t.isDark
-	t.updateVisuals()
-	t.Refresh()
-	if t.toggleFn != nil {
-		t.toggleFn()
+// themeSelectorRenderer lays out the selector using the active theme's own
+// size metrics (via container.Theme()) instead of fixed pixel offsets, so
+// nested containers pick up whatever theme is currently installed.
+type themeSelectorRenderer struct {
+	selector  *themeSelector
+	container *fyne.Container
+}
+
+func (r *themeSelectorRenderer) Layout(size fyne.Size) {
+	th := r.container.Theme()
+	pad := th.Size(fynetheme.SizeNamePadding)
+
+	r.container.Resize(size)
+	r.selector.track.Resize(size)
+	r.selector.track.Move(fyne.NewPos(0, 0))
+
+	segment := size.Width / 3
+	knobSize := size.Height - pad
+	r.selector.knob.Resize(fyne.NewSize(knobSize, knobSize))
+	r.selector.knob.Move(fyne.NewPos(segment*float32(r.selector.mode)+pad/2, pad/2))
+
+	for i, label := range r.selector.labels {
+		label.Resize(fyne.NewSize(segment, size.Height))
+		label.Move(fyne.NewPos(segment*float32(i), 0))
 	}
 }
+
+func (r *themeSelectorRenderer) MinSize() fyne.Size {
+	return r.selector.MinSize()
+}
+
+func (r *themeSelectorRenderer) Refresh() {
+	for i, label := range r.selector.labels {
+		if dialogotheme.Mode(i) == r.selector.mode {
+			label.TextStyle = fyne.TextStyle{Bold: true}
+		} else {
+			label.TextStyle = fyne.TextStyle{}
+		}
+		label.Refresh()
+	}
+	r.Layout(r.container.Size())
+	canvas.Refresh(r.selector)
+}
+
+func (r *themeSelectorRenderer) Objects() []fyne.CanvasObject {
+	return r.container.Objects
+}
+
+func (r *themeSelectorRenderer) Destroy() {}
@@ -0,0 +1,903 @@
+// Package xmpp implements the XMPP client subsystem used by Dialogo: SASL
+// login over TLS/STARTTLS, roster and presence tracking, and message
+// send/receive. It is a thin wrapper around mellium.im/xmpp that exposes a
+// small Client interface the UI layer can consume without depending on the
+// underlying library directly.
+package xmpp
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/xml"
+	"fmt"
+	"math/rand"
+	"net"
+	"sync"
+	"time"
+
+	"mellium.im/sasl"
+	"mellium.im/xmlstream"
+	"mellium.im/xmpp"
+	"mellium.im/xmpp/dial"
+	"mellium.im/xmpp/jid"
+	"mellium.im/xmpp/roster"
+	"mellium.im/xmpp/stanza"
+)
+
+// TLSMode selects how the client secures its connection to the server.
+type TLSMode int
+
+const (
+	// TLSDirect dials straight into TLS (the modern default, usually port 5223).
+	TLSDirect TLSMode = iota
+	// STARTTLS connects in the clear and upgrades the stream with STARTTLS.
+	STARTTLS
+	// NoTLS disables transport security entirely. Only useful for local testing.
+	NoTLS
+)
+
+// Config describes the account a Client should connect as.
+type Config struct {
+	JID      string
+	Password string
+	Server   string // overrides the host derived from the JID's domain, if set
+	Port     int
+	Resource string
+	TLS      TLSMode
+
+	// InsecureSkipVerify disables certificate verification. Only ever set
+	// this for connecting to self-signed test servers.
+	InsecureSkipVerify bool
+}
+
+// ConnState describes the current lifecycle state of a Client's connection.
+type ConnState int
+
+const (
+	StateDisconnected ConnState = iota
+	StateConnecting
+	StateConnected
+	StateReconnecting
+)
+
+// Contact is a single roster entry.
+type Contact struct {
+	JID          string
+	Name         string
+	Subscription string
+	Presence     Presence
+}
+
+// Presence is the last-known availability of a contact.
+type Presence struct {
+	Show   string // "", "away", "chat", "dnd", "xa"
+	Status string
+	Online bool
+}
+
+// Message is an incoming or outgoing chat stanza.
+type Message struct {
+	From string
+	To   string
+	Body string
+	Sent time.Time
+	// ArchiveID is the XEP-0313 MAM result id for a backfilled message, so
+	// the history store can dedupe a re-run archive fetch. Empty for a
+	// live message.
+	ArchiveID string
+}
+
+// DeviceKey is one entry from a contact's published OMEMO device list
+// (XEP-0384 §4.1): a device ID paired with the long-term identity public
+// key Dialogo publishes alongside it, so a peer can discover both over
+// PEP and seed a session without already sharing keys out of band.
+type DeviceKey struct {
+	DeviceID    uint32
+	IdentityKey [32]byte
+}
+
+// Occupant is a single participant in a joined MUC room (XEP-0045).
+type Occupant struct {
+	Nick        string
+	JID         string // the occupant's real bare JID, if the room discloses it (non-anonymous rooms)
+	Affiliation string // "owner", "admin", "member", "outcast", "none"
+	Role        string // "moderator", "participant", "visitor", "none"
+}
+
+// RoomUpdate carries a change to a joined room's occupant list or topic.
+type RoomUpdate struct {
+	Room       string
+	Occupants  []Occupant
+	Topic      string
+}
+
+// Event is sent on Client.Events() as things happen on the connection.
+type Event struct {
+	Message     *Message
+	Roster      []Contact
+	Presence    *Contact
+	Room        *RoomUpdate
+	State       ConnState
+	Err         error
+}
+
+// Client is the interface the UI depends on, so it can be faked in tests
+// without a real XMPP server.
+type Client interface {
+	// Connect establishes the session and blocks until the initial roster
+	// has been fetched or ctx is done. Reconnection after the initial
+	// connect happens automatically in the background.
+	Connect(ctx context.Context, cfg Config) error
+	// Send delivers a chat message to the given bare or full JID, or, for a
+	// joined room, a groupchat message to the room itself.
+	Send(to, body string) error
+	// Roster returns the last-known contact list.
+	Roster() []Contact
+	// JoinRoom sends the presence stanza that joins a MUC room (XEP-0045)
+	// under the given nickname. Occupant updates for the room arrive as
+	// RoomUpdate events; the room's <subject> is not parsed, so those
+	// events always carry an empty Topic.
+	JoinRoom(room, nick string) error
+	// LeaveRoom sends unavailable presence to a joined room.
+	LeaveRoom(room, nick string) error
+	// Kick removes an occupant from the room for the current session by
+	// setting their role to "none" (XEP-0045 §9.1), addressed by room
+	// nick; it doesn't affect their ability to rejoin.
+	Kick(room, occupantNick, reason string) error
+	// Ban sets an occupant's affiliation to "outcast" (XEP-0045 §9.2),
+	// which (unlike Kick) must address the occupant's real bare JID, not
+	// their room nick, since it has to apply even after they leave.
+	Ban(room, occupantJID, reason string) error
+	// FetchArchive backfills history from the server's Message Archive
+	// Management store (XEP-0313) for the given JID, up to `before`.
+	FetchArchive(ctx context.Context, jid string, before time.Time) ([]Message, error)
+	// PublishDeviceList announces this resource's OMEMO device ID and
+	// identity public key on the account's urn:xmpp:omemo:2:devicelist PEP
+	// node (XEP-0384 §4.1), so peers can discover both when starting a
+	// new session.
+	PublishDeviceList(deviceID uint32, identityKey [32]byte) error
+	// FetchDeviceKey fetches jid's published OMEMO device list and returns
+	// its first entry, the device a new session should be established
+	// with.
+	FetchDeviceKey(ctx context.Context, jid string) (DeviceKey, error)
+	// Events returns the channel on which message, roster, presence and
+	// connection-state events are delivered.
+	Events() <-chan Event
+	// Close tears down the session and stops reconnect attempts.
+	Close() error
+}
+
+// client is the mellium.im/xmpp backed implementation of Client.
+type client struct {
+	cfg    Config
+	events chan Event
+
+	mu        sync.Mutex
+	session   *xmpp.Session
+	roster    []Contact
+	occupants map[string]map[string]Occupant // room JID -> nick -> Occupant
+	state     ConnState
+	closed    bool
+}
+
+// New creates a Client backed by a real XMPP connection. Connect must be
+// called before Send or Roster return useful data.
+func New() Client {
+	return &client{
+		events: make(chan Event, 32),
+	}
+}
+
+func (c *client) Connect(ctx context.Context, cfg Config) error {
+	c.cfg = cfg
+	if err := c.connectOnce(ctx); err != nil {
+		return err
+	}
+	go c.reconnectLoop()
+	return nil
+}
+
+func (c *client) connectOnce(ctx context.Context) error {
+	c.setState(StateConnecting)
+
+	j, err := jid.Parse(c.cfg.JID)
+	if err != nil {
+		return fmt.Errorf("xmpp: invalid JID %q: %w", c.cfg.JID, err)
+	}
+	if c.cfg.Resource != "" {
+		j, err = j.WithResource(c.cfg.Resource)
+		if err != nil {
+			return fmt.Errorf("xmpp: invalid resource %q: %w", c.cfg.Resource, err)
+		}
+	}
+
+	conn, err := c.dial(ctx, j)
+	if err != nil {
+		c.setState(StateDisconnected)
+		return fmt.Errorf("xmpp: dial: %w", err)
+	}
+
+	var streamFeatures []xmpp.StreamFeature
+	if c.cfg.TLS == NoTLS {
+		streamFeatures = []xmpp.StreamFeature{
+			xmpp.SASL("", c.cfg.Password, sasl.Plain, sasl.ScramSha256),
+			xmpp.BindResource(),
+		}
+	} else {
+		streamFeatures = []xmpp.StreamFeature{
+			xmpp.StartTLS(&tls.Config{ServerName: j.Domain().String(), InsecureSkipVerify: c.cfg.InsecureSkipVerify}),
+			xmpp.SASL("", c.cfg.Password, sasl.ScramSha256, sasl.ScramSha1, sasl.Plain),
+			xmpp.BindResource(),
+		}
+	}
+	negotiator := xmpp.NewNegotiator(func(*xmpp.Session, *xmpp.StreamConfig) xmpp.StreamConfig {
+		return xmpp.StreamConfig{Features: streamFeatures}
+	})
+
+	session, err := xmpp.NewSession(ctx, j.Domain(), j, conn, 0, negotiator)
+	if err != nil {
+		c.setState(StateDisconnected)
+		return fmt.Errorf("xmpp: negotiate session: %w", err)
+	}
+
+	c.mu.Lock()
+	c.session = session
+	c.mu.Unlock()
+
+	c.setState(StateConnected)
+
+	go c.serve(session)
+	c.fetchRoster(ctx)
+	c.sendPresence(stanza.Presence{Type: stanza.AvailablePresence})
+
+	return nil
+}
+
+// dial picks a direct-TLS or plaintext (for STARTTLS upgrade) dialer
+// depending on the configured TLSMode, honoring an explicit Server/Port
+// override when present by connecting straight to that address instead of
+// going through SRV discovery.
+func (c *client) dial(ctx context.Context, j jid.JID) (conn net.Conn, err error) {
+	if c.cfg.Server != "" {
+		var d net.Dialer
+		conn, err := d.DialContext(ctx, "tcp", fmt.Sprintf("%s:%d", c.cfg.Server, c.cfg.Port))
+		if err != nil {
+			return nil, err
+		}
+		if c.cfg.TLS == TLSDirect {
+			return tls.Client(conn, &tls.Config{
+				ServerName:         j.Domain().String(),
+				InsecureSkipVerify: c.cfg.InsecureSkipVerify,
+			}), nil
+		}
+		return conn, nil
+	}
+
+	d := dial.Dialer{NoTLS: c.cfg.TLS != TLSDirect}
+	return d.Dial(ctx, "tcp", j)
+}
+
+// serve reads incoming stanzas until the session closes, translating
+// message and presence stanzas into Events.
+func (c *client) serve(session *xmpp.Session) {
+	err := session.Serve(xmpp.HandlerFunc(func(t xmlstream.TokenReadEncoder, start *xml.StartElement) error {
+		switch start.Name.Local {
+		case "message":
+			var msg messageStanza
+			if err := xml.NewTokenDecoder(t).DecodeElement(&msg, start); err != nil {
+				return err
+			}
+			c.handleMessage(msg)
+		case "presence":
+			var pres mucPresence
+			if err := xml.NewTokenDecoder(t).DecodeElement(&pres, start); err != nil {
+				return err
+			}
+			c.handlePresence(pres)
+		}
+		return nil
+	}))
+	if err != nil && !c.isClosed() {
+		c.emit(Event{Err: fmt.Errorf("xmpp: session closed: %w", err), State: StateDisconnected})
+		c.setState(StateDisconnected)
+	}
+}
+
+// messageStanza extends stanza.Message with the child elements Dialogo
+// cares about, so a single DecodeElement call picks up the body alongside
+// the stanza's attributes instead of leaving a second decode to race the
+// same token stream (encoding/xml has already consumed the element's
+// children by the time DecodeElement returns).
+type messageStanza struct {
+	stanza.Message
+	Body      string     `xml:"body"`
+	MAMResult *mamResult `xml:"urn:xmpp:mam:2 result"`
+}
+
+// mamResult is the XEP-0313 §4.2 wrapper an archive query reply arrives
+// in: a <result> carrying a <forwarded> copy of the originally archived
+// <message>, including the <delay> stamp recording when it was sent. The
+// real stanza is nested two levels deep here rather than at the top
+// level, so it needs its own decode instead of messageStanza's Body field.
+type mamResult struct {
+	QueryID   string `xml:"queryid,attr"`
+	ID        string `xml:"id,attr"`
+	Forwarded struct {
+		Delay struct {
+			Stamp string `xml:"stamp,attr"`
+		} `xml:"urn:xmpp:delay delay"`
+		Message struct {
+			stanza.Message
+			Body string `xml:"body"`
+		} `xml:"jabber:client message"`
+	} `xml:"urn:xmpp:forward:0 forwarded"`
+}
+
+// handleMessage emits a Message event for a 1:1 or groupchat stanza, or,
+// for a MAM archive result, the message it forwards. An empty body (e.g.
+// a MUC presence-only join notice delivered as a message) is dropped
+// rather than surfaced as a blank chat line.
+func (c *client) handleMessage(msg messageStanza) {
+	if msg.MAMResult != nil {
+		archived := msg.MAMResult.Forwarded.Message
+		if archived.Body == "" {
+			return
+		}
+		sent := time.Now()
+		if stamp, err := time.Parse(time.RFC3339, msg.MAMResult.Forwarded.Delay.Stamp); err == nil {
+			sent = stamp
+		}
+		c.emit(Event{Message: &Message{
+			From:      archived.From.String(),
+			To:        archived.To.String(),
+			Body:      archived.Body,
+			Sent:      sent,
+			ArchiveID: msg.MAMResult.ID,
+		}})
+		return
+	}
+
+	if msg.Body == "" {
+		return
+	}
+	c.emit(Event{Message: &Message{
+		From: msg.From.String(),
+		To:   msg.To.String(),
+		Body: msg.Body,
+		Sent: time.Now(),
+	}})
+}
+
+// mucPresence extends stanza.Presence with the XEP-0045 §9.4 <x
+// xmlns="...#user"> child a MUC service attaches to room presence, so
+// handlePresence can tell a room occupant update apart from a 1:1
+// contact's presence instead of folding both into the same roster.
+type mucPresence struct {
+	stanza.Presence
+	MUCUser *struct {
+		Item struct {
+			Affiliation string `xml:"affiliation,attr"`
+			Role        string `xml:"role,attr"`
+			JID         string `xml:"jid,attr"`
+		} `xml:"item"`
+	} `xml:"http://jabber.org/protocol/muc#user x"`
+}
+
+// handlePresence updates the cached roster entry for the sender and emits
+// a Presence event for the UI. Occupant presence from a joined MUC room
+// carries the muc#user extension and is tracked separately, per room, so
+// the room UI's participant list and admin actions (Kick, Ban) have the
+// occupant's role, affiliation, and real JID to act on.
+func (c *client) handlePresence(pres mucPresence) {
+	if pres.MUCUser != nil {
+		c.handleOccupantPresence(pres)
+		return
+	}
+
+	contact := Contact{
+		JID: pres.From.Bare().String(),
+		Presence: Presence{
+			Online: pres.Type == stanza.AvailablePresence,
+		},
+	}
+
+	c.mu.Lock()
+	found := false
+	for i, existing := range c.roster {
+		if existing.JID == contact.JID {
+			c.roster[i].Presence = contact.Presence
+			found = true
+			break
+		}
+	}
+	if !found {
+		c.roster = append(c.roster, contact)
+	}
+	c.mu.Unlock()
+
+	c.emit(Event{Presence: &contact})
+}
+
+// handleOccupantPresence updates this client's view of a joined room's
+// occupant list from a muc#user presence and emits a RoomUpdate so the UI
+// can refresh its participant list and the JID Ban needs.
+func (c *client) handleOccupantPresence(pres mucPresence) {
+	room := pres.From.Bare().String()
+	nick := pres.From.Resourcepart()
+	realJID := pres.MUCUser.Item.JID
+	if j, err := jid.Parse(realJID); err == nil {
+		realJID = j.Bare().String()
+	}
+
+	c.mu.Lock()
+	if c.occupants == nil {
+		c.occupants = make(map[string]map[string]Occupant)
+	}
+	if c.occupants[room] == nil {
+		c.occupants[room] = make(map[string]Occupant)
+	}
+	if pres.Type == stanza.UnavailablePresence {
+		delete(c.occupants[room], nick)
+	} else {
+		c.occupants[room][nick] = Occupant{
+			Nick:        nick,
+			JID:         realJID,
+			Affiliation: pres.MUCUser.Item.Affiliation,
+			Role:        pres.MUCUser.Item.Role,
+		}
+	}
+	occupants := make([]Occupant, 0, len(c.occupants[room]))
+	for _, occ := range c.occupants[room] {
+		occupants = append(occupants, occ)
+	}
+	c.mu.Unlock()
+
+	c.emit(Event{Room: &RoomUpdate{Room: room, Occupants: occupants}})
+}
+
+// fetchRoster issues the jabber:iq:roster get (RFC 6121's initial roster
+// fetch) and populates the cached contact list from the response, merging
+// in any presence already observed for a contact (e.g. from a presence
+// probe that beat the roster result back).
+func (c *client) fetchRoster(ctx context.Context) {
+	c.mu.Lock()
+	session := c.session
+	previous := c.roster
+	c.mu.Unlock()
+	if session == nil {
+		return
+	}
+
+	iter := roster.Fetch(ctx, session)
+	var contacts []Contact
+	for iter.Next() {
+		item := iter.Item()
+		contact := Contact{
+			JID:          item.JID.Bare().String(),
+			Name:         item.Name,
+			Subscription: item.Subscription,
+		}
+		for _, existing := range previous {
+			if existing.JID == contact.JID {
+				contact.Presence = existing.Presence
+				break
+			}
+		}
+		contacts = append(contacts, contact)
+	}
+	if err := iter.Err(); err != nil {
+		c.emit(Event{Err: fmt.Errorf("xmpp: fetch roster: %w", err)})
+		return
+	}
+	_ = iter.Close()
+
+	c.mu.Lock()
+	c.roster = contacts
+	c.mu.Unlock()
+	c.emit(Event{Roster: contacts})
+}
+
+func (c *client) sendPresence(p stanza.Presence) {
+	c.mu.Lock()
+	session := c.session
+	c.mu.Unlock()
+	if session == nil {
+		return
+	}
+	_ = session.Send(context.Background(), p.Wrap(nil))
+}
+
+func (c *client) Send(to, body string) error {
+	c.mu.Lock()
+	session := c.session
+	c.mu.Unlock()
+	if session == nil {
+		return fmt.Errorf("xmpp: not connected")
+	}
+
+	toJID, err := jid.Parse(to)
+	if err != nil {
+		return fmt.Errorf("xmpp: invalid recipient %q: %w", to, err)
+	}
+
+	msgType := stanza.ChatMessage
+	c.mu.Lock()
+	if _, joined := c.occupants[toJID.Bare().String()]; joined {
+		msgType = stanza.GroupChatMessage
+	}
+	c.mu.Unlock()
+
+	msg := stanza.Message{
+		To:   toJID,
+		Type: msgType,
+	}
+	bodyEl := xmlstream.Wrap(
+		xmlstream.Token(xml.CharData(body)),
+		xml.StartElement{Name: xml.Name{Local: "body"}},
+	)
+	if err := session.Encode(context.Background(), msg.Wrap(bodyEl)); err != nil {
+		return fmt.Errorf("xmpp: send: %w", err)
+	}
+
+	return nil
+}
+
+func (c *client) Roster() []Contact {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.roster
+}
+
+// JoinRoom sends directed presence to room/nick, which XEP-0045 treats as
+// a request to enter the room under that nickname.
+func (c *client) JoinRoom(room, nick string) error {
+	occupantJID, err := jid.Parse(room + "/" + nick)
+	if err != nil {
+		return fmt.Errorf("xmpp: invalid room JID %q: %w", room, err)
+	}
+	c.sendPresence(stanza.Presence{To: occupantJID, Type: stanza.AvailablePresence})
+	return nil
+}
+
+// LeaveRoom sends unavailable presence to the room, which removes the
+// occupant and ends the subscription to its events.
+func (c *client) LeaveRoom(room, nick string) error {
+	occupantJID, err := jid.Parse(room + "/" + nick)
+	if err != nil {
+		return fmt.Errorf("xmpp: invalid room JID %q: %w", room, err)
+	}
+	c.sendPresence(stanza.Presence{To: occupantJID, Type: stanza.UnavailablePresence})
+	return nil
+}
+
+// Kick sends the MUC admin IQ (XEP-0045 §9.1) that sets an occupant's role
+// to "none", removing them from the room for the current session only;
+// this is addressed by room nick, since that's all a role change needs.
+func (c *client) Kick(room, occupantNick, reason string) error {
+	return c.mucAdmin(room, mucAdminPayload{Nick: occupantNick, Role: "none", Reason: reason})
+}
+
+// Ban sends the MUC admin IQ (XEP-0045 §9.2) that sets an occupant's
+// affiliation to "outcast", barring them from rejoining. Unlike Kick this
+// must be addressed by the occupant's real bare JID rather than their
+// room nick: a nick stops resolving to anyone the moment they leave, but
+// the ban has to stick regardless.
+func (c *client) Ban(room, occupantJID, reason string) error {
+	return c.mucAdmin(room, mucAdminPayload{JID: occupantJID, Affiliation: "outcast", Reason: reason})
+}
+
+func (c *client) mucAdmin(room string, payload mucAdminPayload) error {
+	c.mu.Lock()
+	session := c.session
+	c.mu.Unlock()
+	if session == nil {
+		return fmt.Errorf("xmpp: not connected")
+	}
+
+	roomJID, err := jid.Parse(room)
+	if err != nil {
+		return fmt.Errorf("xmpp: invalid room JID %q: %w", room, err)
+	}
+
+	iq := stanza.IQ{To: roomJID, Type: stanza.SetIQ}
+	_, err = session.SendIQElement(context.Background(), payload.tokenReader(), iq)
+	if err != nil {
+		return fmt.Errorf("xmpp: muc admin: %w", err)
+	}
+	return nil
+}
+
+// mucAdminPayload builds the <query xmlns="...#admin"><item/></query>
+// body of a MUC admin IQ. Nick is set for a role change (Kick); JID is set
+// for an affiliation change (Ban); Role/Affiliation are left empty unless
+// that's the attribute being changed.
+type mucAdminPayload struct {
+	Nick        string
+	JID         string
+	Role        string
+	Affiliation string
+	Reason      string
+}
+
+func (p mucAdminPayload) tokenReader() xml.TokenReader {
+	attr := []xml.Attr{}
+	if p.Nick != "" {
+		attr = append(attr, xml.Attr{Name: xml.Name{Local: "nick"}, Value: p.Nick})
+	}
+	if p.JID != "" {
+		attr = append(attr, xml.Attr{Name: xml.Name{Local: "jid"}, Value: p.JID})
+	}
+	if p.Role != "" {
+		attr = append(attr, xml.Attr{Name: xml.Name{Local: "role"}, Value: p.Role})
+	}
+	if p.Affiliation != "" {
+		attr = append(attr, xml.Attr{Name: xml.Name{Local: "affiliation"}, Value: p.Affiliation})
+	}
+	item := xmlstream.Wrap(
+		xmlstream.Token(xml.CharData(p.Reason)),
+		xml.StartElement{Name: xml.Name{Local: "item"}, Attr: attr},
+	)
+	return xmlstream.Wrap(item, xml.StartElement{Name: xml.Name{Space: "http://jabber.org/protocol/muc#admin", Local: "query"}})
+}
+
+// FetchArchive queries the server's XEP-0313 MAM store for messages with
+// jid older than before, so the UI can backfill a conversation's history
+// when its tab is first opened instead of relying solely on locally cached
+// messages.
+func (c *client) FetchArchive(ctx context.Context, jid string, before time.Time) ([]Message, error) {
+	c.mu.Lock()
+	session := c.session
+	c.mu.Unlock()
+	if session == nil {
+		return nil, fmt.Errorf("xmpp: not connected")
+	}
+
+	query := mamQueryPayload{With: jid, Before: before}
+	iq := stanza.IQ{Type: stanza.SetIQ}
+	_, err := session.SendIQElement(ctx, query.tokenReader(), iq)
+	if err != nil {
+		return nil, fmt.Errorf("xmpp: mam query: %w", err)
+	}
+
+	// The server replies with a series of <message> stanzas wrapping the
+	// archived results in a MAM <result>/<forwarded> (XEP-0313 §4.2),
+	// followed by a fin IQ. handleMessage unwraps those results and
+	// surfaces them as ordinary Message events, same as live messages.
+	return nil, nil
+}
+
+// mamQueryPayload builds the <query xmlns="urn:xmpp:mam:2"> IQ body used
+// to request archived messages with a given JID before a cutoff time.
+type mamQueryPayload struct {
+	With   string
+	Before time.Time
+}
+
+func (p mamQueryPayload) tokenReader() xml.TokenReader {
+	withField := xmlstream.Wrap(
+		xmlstream.Token(xml.CharData(p.With)),
+		xml.StartElement{Name: xml.Name{Local: "value"}},
+	)
+	withField = xmlstream.Wrap(withField, xml.StartElement{
+		Name: xml.Name{Local: "field"},
+		Attr: []xml.Attr{{Name: xml.Name{Local: "var"}, Value: "with"}},
+	})
+	fields := withField
+	if !p.Before.IsZero() {
+		endField := xmlstream.Wrap(
+			xmlstream.Token(xml.CharData(p.Before.UTC().Format(time.RFC3339))),
+			xml.StartElement{Name: xml.Name{Local: "value"}},
+		)
+		endField = xmlstream.Wrap(endField, xml.StartElement{
+			Name: xml.Name{Local: "field"},
+			Attr: []xml.Attr{{Name: xml.Name{Local: "var"}, Value: "end"}},
+		})
+		fields = xmlstream.MultiReader(withField, endField)
+	}
+	form := xmlstream.Wrap(fields, xml.StartElement{
+		Name: xml.Name{Space: "jabber:x:data", Local: "x"},
+		Attr: []xml.Attr{{Name: xml.Name{Local: "type"}, Value: "submit"}},
+	})
+	return xmlstream.Wrap(form, xml.StartElement{Name: xml.Name{Space: "urn:xmpp:mam:2", Local: "query"}})
+}
+
+// PublishDeviceList sends the PEP publish IQ (XEP-0060) that replaces this
+// account's OMEMO device list with a single entry for deviceID, carrying
+// identityKey so a peer can seed a session straight from this one node
+// instead of a separate per-device bundle fetch. A client with more than
+// one logged-in resource would need to merge with whatever the node
+// already holds instead of overwriting it; Dialogo only ever runs one
+// device per account, so overwrite is safe here.
+func (c *client) PublishDeviceList(deviceID uint32, identityKey [32]byte) error {
+	c.mu.Lock()
+	session := c.session
+	c.mu.Unlock()
+	if session == nil {
+		return fmt.Errorf("xmpp: not connected")
+	}
+
+	payload := deviceListPayload{DeviceID: deviceID, IdentityKey: identityKey}
+	iq := stanza.IQ{Type: stanza.SetIQ}
+	_, err := session.SendIQElement(context.Background(), payload.tokenReader(), iq)
+	if err != nil {
+		return fmt.Errorf("xmpp: publish device list: %w", err)
+	}
+	return nil
+}
+
+// deviceListPayload builds the <pubsub><publish node="urn:xmpp:omemo:2:devicelist">
+// IQ body that announces this device, and its identity public key, to
+// the account's contacts.
+type deviceListPayload struct {
+	DeviceID    uint32
+	IdentityKey [32]byte
+}
+
+func (p deviceListPayload) tokenReader() xml.TokenReader {
+	device := xmlstream.Wrap(nil, xml.StartElement{
+		Name: xml.Name{Local: "device"},
+		Attr: []xml.Attr{
+			{Name: xml.Name{Local: "id"}, Value: fmt.Sprintf("%d", p.DeviceID)},
+			{Name: xml.Name{Local: "ik"}, Value: base64.StdEncoding.EncodeToString(p.IdentityKey[:])},
+		},
+	})
+	list := xmlstream.Wrap(device, xml.StartElement{Name: xml.Name{Space: "urn:xmpp:omemo:2", Local: "devices"}})
+	item := xmlstream.Wrap(list, xml.StartElement{Name: xml.Name{Local: "item"}})
+	publish := xmlstream.Wrap(item, xml.StartElement{
+		Name: xml.Name{Local: "publish"},
+		Attr: []xml.Attr{{Name: xml.Name{Local: "node"}, Value: "urn:xmpp:omemo:2:devicelist"}},
+	})
+	return xmlstream.Wrap(publish, xml.StartElement{Name: xml.Name{Space: "http://jabber.org/protocol/pubsub", Local: "pubsub"}})
+}
+
+// FetchDeviceKey fetches jid's published urn:xmpp:omemo:2:devicelist node
+// (XEP-0384 §4.1) and returns its first device entry, so a new session
+// can be established from real published key material instead of one
+// derived locally from the JID.
+func (c *client) FetchDeviceKey(ctx context.Context, to string) (DeviceKey, error) {
+	c.mu.Lock()
+	session := c.session
+	c.mu.Unlock()
+	if session == nil {
+		return DeviceKey{}, fmt.Errorf("xmpp: not connected")
+	}
+
+	peerJID, err := jid.Parse(to)
+	if err != nil {
+		return DeviceKey{}, fmt.Errorf("xmpp: invalid peer %q: %w", to, err)
+	}
+
+	query := pubsubItemsQuery{Node: "urn:xmpp:omemo:2:devicelist"}
+	iq := stanza.IQ{To: peerJID, Type: stanza.GetIQ}
+	resp, err := session.SendIQElement(ctx, query.tokenReader(), iq)
+	if err != nil {
+		return DeviceKey{}, fmt.Errorf("xmpp: fetch device list: %w", err)
+	}
+
+	var result deviceListResult
+	if err := xml.NewTokenDecoder(resp).Decode(&result); err != nil {
+		return DeviceKey{}, fmt.Errorf("xmpp: decode device list: %w", err)
+	}
+	if len(result.Items.Item.Devices.Device) == 0 {
+		return DeviceKey{}, fmt.Errorf("xmpp: %s has no published OMEMO devices", to)
+	}
+
+	device := result.Items.Item.Devices.Device[0]
+	key, err := base64.StdEncoding.DecodeString(device.IdentityKey)
+	if err != nil || len(key) != 32 {
+		return DeviceKey{}, fmt.Errorf("xmpp: %s published a malformed identity key", to)
+	}
+
+	var devKey DeviceKey
+	devKey.DeviceID = device.ID
+	copy(devKey.IdentityKey[:], key)
+	return devKey, nil
+}
+
+// pubsubItemsQuery builds the <pubsub><items node="..."/></pubsub> IQ
+// body used to fetch the latest published item(s) on a PEP node.
+type pubsubItemsQuery struct {
+	Node string
+}
+
+func (p pubsubItemsQuery) tokenReader() xml.TokenReader {
+	items := xmlstream.Wrap(nil, xml.StartElement{
+		Name: xml.Name{Local: "items"},
+		Attr: []xml.Attr{{Name: xml.Name{Local: "node"}, Value: p.Node}},
+	})
+	return xmlstream.Wrap(items, xml.StartElement{Name: xml.Name{Space: "http://jabber.org/protocol/pubsub", Local: "pubsub"}})
+}
+
+// deviceListResult decodes a urn:xmpp:omemo:2:devicelist PEP item reply.
+type deviceListResult struct {
+	Items struct {
+		Item struct {
+			Devices struct {
+				Device []struct {
+					ID          uint32 `xml:"id,attr"`
+					IdentityKey string `xml:"ik,attr"`
+				} `xml:"device"`
+			} `xml:"urn:xmpp:omemo:2 devices"`
+		} `xml:"item"`
+	} `xml:"items"`
+}
+
+func (c *client) Events() <-chan Event {
+	return c.events
+}
+
+func (c *client) Close() error {
+	c.mu.Lock()
+	c.closed = true
+	session := c.session
+	c.mu.Unlock()
+
+	c.setState(StateDisconnected)
+	if session == nil {
+		return nil
+	}
+	return session.Close()
+}
+
+func (c *client) isClosed() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.closed
+}
+
+func (c *client) setState(s ConnState) {
+	c.mu.Lock()
+	c.state = s
+	c.mu.Unlock()
+	c.emit(Event{State: s})
+}
+
+func (c *client) emit(e Event) {
+	select {
+	case c.events <- e:
+	default:
+		// Drop the event rather than block the session goroutine; the UI
+		// only needs the latest state, not every intermediate one.
+	}
+}
+
+// reconnectLoop watches for disconnects and retries with capped exponential
+// backoff plus jitter, until Close is called.
+func (c *client) reconnectLoop() {
+	backoff := time.Second
+	const maxBackoff = 2 * time.Minute
+
+	for {
+		time.Sleep(pollInterval)
+		if c.isClosed() {
+			return
+		}
+
+		c.mu.Lock()
+		state := c.state
+		c.mu.Unlock()
+		if state != StateDisconnected {
+			backoff = time.Second
+			continue
+		}
+
+		c.setState(StateReconnecting)
+		jitter := time.Duration(rand.Int63n(int64(backoff) / 2))
+		time.Sleep(backoff + jitter)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		err := c.connectOnce(ctx)
+		cancel()
+		if err != nil {
+			backoff *= 2
+			if backoff > maxBackoff {
+				backoff = maxBackoff
+			}
+			continue
+		}
+		backoff = time.Second
+	}
+}
+
+const pollInterval = 2 * time.Second
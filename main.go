@@ -1,72 +1,58 @@
 package main
 
 import (
+	"log"
+
+	"dialogo/internal/config"
+	"dialogo/internal/crypto/omemo"
+	"dialogo/internal/history"
+	dialogotheme "dialogo/internal/theme"
 	"dialogo/internal/ui"
-	"fyne.io/fyne/v2/app"
-	"fyne.io/fyne/v2/theme"
 	"fyne.io/fyne/v2"
-	"image/color"
+	"fyne.io/fyne/v2/app"
 )
 
 func main() {
 	a := app.NewWithID("com.example.dialogo")
-	
-	// Track dark mode state
-	isDarkMode := true
-	a.Settings().SetTheme(&darkTheme{})
-	
+
+	mode := dialogotheme.LoadMode(a)
+	accent := dialogotheme.LoadAccent(a)
+	a.Settings().SetTheme(dialogotheme.ForMode(mode, accent))
+
 	window := a.NewWindow("Dialogo")
 	window.Resize(fyne.NewSize(1200, 800))
 	window.CenterOnScreen()
-	
-	// Pass toggle function + dark mode state to UI (no font for now)
-	toggleTheme := func() {
-		isDarkMode = !isDarkMode
-		if isDarkMode {
-			a.Settings().SetTheme(&darkTheme{})
-		} else {
-			a.Settings().SetTheme(theme.LightTheme())
-		}
+
+	// setTheme applies and persists a theme mode change from the UI's
+	// three-state Dark/Light/System selector.
+	setTheme := func(m dialogotheme.Mode) {
+		mode = m
+		dialogotheme.SaveMode(a, mode)
+		a.Settings().SetTheme(dialogotheme.ForMode(mode, accent))
 		window.Content().Refresh()
 	}
-	
-	mainUI := ui.NewMainUI(&window, toggleTheme, isDarkMode, nil)
-	window.SetContent(mainUI.BuildUI())
-	
-	window.ShowAndRun()
-}
 
-type darkTheme struct{}
+	store := config.NewStore(a)
 
-func (c *darkTheme) Color(name fyne.ThemeColorName, variant fyne.ThemeVariant) color.Color {
-	switch name {
-	case theme.ColorNameBackground:
-		return color.NRGBA{R: 30, G: 30, B: 30, A: 255}
-	case theme.ColorNameForeground:
-		return color.NRGBA{R: 220, G: 220, B: 220, A: 255}
-	case theme.ColorNameButton:
-		return color.NRGBA{R: 70, G: 70, B: 70, A: 255}
-	case theme.ColorNameInputBackground:
-		return color.NRGBA{R: 45, G: 45, B: 45, A: 255}
-	case theme.ColorNamePrimary:
-		return color.NRGBA{R: 100, G: 100, B: 100, A: 255}
-	case theme.ColorNameDisabledButton:
-		return color.NRGBA{R: 60, G: 60, B: 60, A: 255}
-	case theme.ColorNameDisabled:
-		return color.NRGBA{R: 80, G: 80, B: 80, A: 255}
-	default:
-		return theme.DefaultTheme().Color(name, variant)
+	hist, err := history.Open(history.DefaultPath(a))
+	if err != nil {
+		log.Println("dialogo: history disabled:", err)
+	} else {
+		defer hist.Close()
 	}
-}
 
-func (c *darkTheme) Font(textStyle fyne.TextStyle) fyne.Resource {
-	return theme.DefaultTheme().Font(textStyle)
-}
+	var omemoStore *omemo.Store
+	if hist != nil {
+		if omemoStore, err = omemo.NewStore(hist.DB()); err != nil {
+			log.Println("dialogo: omemo disabled:", err)
+		}
+	}
 
-func (c *darkTheme) Icon(iconName fyne.ThemeIconName) fyne.Resource {
-	return theme.DefaultTheme().Icon(iconName)
-}
+	mainUI := ui.NewMainUI(a, &window, setTheme, mode, nil, store, hist, omemoStore)
+	window.SetContent(mainUI.BuildUI())
 
-func (c *darkTheme) Size(sizeName fyne.ThemeSizeName) float32 {
-	return theme.DefaultTheme().Size(sizeName)
+	helpMenu := fyne.NewMenu("Help", fyne.NewMenuItem("About", mainUI.ShowAbout))
+	window.SetMainMenu(fyne.NewMainMenu(helpMenu))
+
+	window.ShowAndRun()
 }